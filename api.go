@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Public API
+// ==============================================================================
+// NOTE(fusion): Read-only JSON mirror of the public pages, served from the same
+// cached data as the templates. Field names are defined by the `json` tags in
+// `query.go` and are part of the API so they must NOT be changed. Anything that
+// would break clients should go into a new version under a different prefix.
+//
+//	GET /api/v1/worlds
+//	GET /api/v1/worlds/{world}
+//	GET /api/v1/worlds/{world}/killstatistics
+//	GET /api/v1/characters/{name}
+//
+// Account endpoints require a personal access token, sent as a bearer token in
+// the `Authorization` header, with the appropriate scope. Session cookies are
+// NOT accepted by the API.
+//
+//	GET  /api/v1/account
+//	POST /api/v1/account/characters
+type (
+	TApiError struct {
+		Error string `json:"error"`
+	}
+
+	TApiWorlds struct {
+		Worlds  []TWorld `json:"worlds"`
+		Updated int64    `json:"updated"`
+	}
+
+	TApiWorld struct {
+		World            *TWorld            `json:"world"`
+		OnlineCharacters []TOnlineCharacter `json:"online_characters"`
+		Updated          int64              `json:"updated"`
+	}
+
+	TApiKillStatistics struct {
+		World          string            `json:"world"`
+		KillStatistics []TKillStatistics `json:"kill_statistics"`
+		Updated        int64             `json:"updated"`
+	}
+
+	TApiCharacter struct {
+		TCharacterProfile
+		Premium bool `json:"premium"`
+	}
+
+	TApiCharacterCreate struct {
+		World string `json:"world"`
+		Name  string `json:"name"`
+		Sex   int    `json:"sex"`
+	}
+)
+
+// NOTE(fusion): Slices are always encoded as arrays, even when empty, so clients
+// don't need to handle `null`.
+func NonNil[T any](Slice []T) []T {
+	if Slice == nil {
+		return []T{}
+	}
+	return Slice
+}
+
+func ApiTimestamp(Time time.Time) int64 {
+	if Time.IsZero() {
+		return 0
+	}
+	return Time.Unix()
+}
+
+func ApiAllowedOrigin(Origin string) string {
+	if Origin == "" || g_ApiAllowedOrigins == "" {
+		return ""
+	}
+
+	for _, Allowed := range SplitDiscardEmpty(g_ApiAllowedOrigins, ",") {
+		Allowed = strings.TrimSpace(Allowed)
+		if Allowed == "*" {
+			return "*"
+		} else if strings.EqualFold(Allowed, Origin) {
+			return Origin
+		}
+	}
+
+	return ""
+}
+
+func ApiSetCorsHeaders(Context *THttpRequestContext) {
+	Header := Context.Writer.Header()
+	Header.Add("Vary", "Origin")
+	if Origin := ApiAllowedOrigin(Context.Request.Header.Get("Origin")); Origin != "" {
+		Header.Set("Access-Control-Allow-Origin", Origin)
+		Header.Set("Access-Control-Expose-Headers", "ETag")
+	}
+}
+
+// NOTE(fusion): `If-None-Match` may contain a list of entity tags, possibly weak,
+// or `*` to match anything.
+func ApiETagMatches(IfNoneMatch string, ETag string) bool {
+	for _, Candidate := range strings.Split(IfNoneMatch, ",") {
+		Candidate = strings.TrimPrefix(strings.TrimSpace(Candidate), "W/")
+		if Candidate == "*" || Candidate == ETag {
+			return true
+		}
+	}
+	return false
+}
+
+func ApiWrite(Context *THttpRequestContext, Status int, Value any) {
+	Body, Err := json.Marshal(Value)
+	if Err != nil {
+		g_LogErr.Printf("Failed to encode API response: %v", Err)
+		Status = http.StatusInternalServerError
+		Body = []byte(`{"error":"internal error"}`)
+	}
+
+	ApiSetCorsHeaders(Context)
+	Header := Context.Writer.Header()
+	Header.Set("Content-Type", "application/json; charset=utf-8")
+	if Context.Request.Header.Get("Authorization") != "" {
+		Header.Set("Cache-Control", "private, no-cache")
+	} else {
+		Header.Set("Cache-Control", "no-cache")
+	}
+	if Status == http.StatusOK {
+		// NOTE(fusion): The tag is derived from the response body so it changes
+		// whenever the cached data does, and is the same across restarts.
+		Hash := sha256.Sum256(Body)
+		ETag := "\"" + hex.EncodeToString(Hash[:16]) + "\""
+		Header.Set("ETag", ETag)
+		if ApiETagMatches(Context.Request.Header.Get("If-None-Match"), ETag) {
+			Context.Writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	Context.Writer.WriteHeader(Status)
+	Context.Writer.Write(Body)
+}
+
+func ApiError(Context *THttpRequestContext, Status int, Message string) {
+	ApiWrite(Context, Status, TApiError{Error: Message})
+}
+
+func ApiNotFound(Context *THttpRequestContext) {
+	ApiError(Context, http.StatusNotFound, "not found")
+}
+
+func HandleApiPreflight(Context *THttpRequestContext) {
+	ApiSetCorsHeaders(Context)
+	Header := Context.Writer.Header()
+	Header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	Header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match")
+	Header.Set("Access-Control-Max-Age", "86400")
+	Context.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func HandleApiWorlds(Context *THttpRequestContext) {
+	switch len(Context.Params) {
+	case 0:
+		ApiWrite(Context, http.StatusOK,
+			TApiWorlds{
+				Worlds:  NonNil(Context.Backend.GetWorlds()),
+				Updated: ApiTimestamp(Context.Backend.GetWorldsUpdated()),
+			})
+	case 1:
+		World := Context.Backend.GetWorld(Context.Params[0])
+		if World == nil {
+			ApiError(Context, http.StatusNotFound, "world not found")
+			return
+		}
+
+		ApiWrite(Context, http.StatusOK,
+			TApiWorld{
+				World:            World,
+				OnlineCharacters: NonNil(Context.Backend.GetOnlineCharacters(World.Name)),
+				Updated:          ApiTimestamp(Context.Backend.GetOnlineCharactersUpdated(World.Name)),
+			})
+	case 2:
+		if Context.Params[1] != "killstatistics" {
+			ApiNotFound(Context)
+			return
+		}
+
+		World := Context.Backend.GetWorld(Context.Params[0])
+		if World == nil {
+			ApiError(Context, http.StatusNotFound, "world not found")
+			return
+		}
+
+		ApiWrite(Context, http.StatusOK,
+			TApiKillStatistics{
+				World:          World.Name,
+				KillStatistics: NonNil(Context.Backend.GetKillStatistics(World.Name)),
+				Updated:        ApiTimestamp(Context.Backend.GetKillStatisticsUpdated(World.Name)),
+			})
+	default:
+		ApiNotFound(Context)
+	}
+}
+
+func HandleApiCharacters(Context *THttpRequestContext) {
+	if len(Context.Params) != 1 {
+		ApiNotFound(Context)
+		return
+	}
+
+	Result, Character := Context.Backend.GetCharacterProfile(Context.Params[0])
+	switch Result {
+	case 0:
+		Character.OtherCharacters = NonNil(Character.OtherCharacters)
+		Character.Deaths = NonNil(Character.Deaths)
+		ApiWrite(Context, http.StatusOK,
+			TApiCharacter{
+				TCharacterProfile: Character,
+				Premium:           Character.PremiumDays > 0,
+			})
+	case 1:
+		ApiError(Context, http.StatusNotFound, "character not found")
+	default:
+		ApiError(Context, http.StatusServiceUnavailable, "unavailable")
+	}
+}
+
+// NOTE(fusion): Returns the account id the request's access token belongs to if
+// it has all `Scopes`. Otherwise an error response is written and zero returned.
+func ApiAuthenticate(Context *THttpRequestContext, Scopes int) int {
+	Token, Ok := strings.CutPrefix(Context.Request.Header.Get("Authorization"), "Bearer ")
+	if !Ok {
+		Context.Writer.Header().Set("WWW-Authenticate", "Bearer")
+		ApiError(Context, http.StatusUnauthorized, "missing access token")
+		return 0
+	}
+
+	Token = strings.TrimSpace(Token)
+	if !strings.HasPrefix(Token, AccessTokenPrefix) {
+		Context.Writer.Header().Set("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+		ApiError(Context, http.StatusUnauthorized, "invalid access token")
+		return 0
+	}
+
+	// NOTE(fusion): Tokens aren't cached, so every check goes to the query
+	// manager. Limit how often anyone can do that, or guessing tokens would be
+	// a free way to flood it.
+	if Wait := g_ApiTokenIPLimiter.Take(Context.IPAddress); Wait > 0 {
+		SetRetryAfter(Context, Wait)
+		ApiError(Context, http.StatusTooManyRequests, "too many requests")
+		return 0
+	}
+
+	Result, AccountID, TokenScopes := Context.Backend.CheckAccessToken(HashAccessToken(Token))
+	switch Result {
+	case 0:
+		if TokenScopes&Scopes != Scopes {
+			Context.Writer.Header().Set("WWW-Authenticate", "Bearer error=\"insufficient_scope\"")
+			ApiError(Context, http.StatusForbidden, "insufficient scope")
+			return 0
+		}
+		return AccountID
+	case 1:
+		Context.Writer.Header().Set("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+		ApiError(Context, http.StatusUnauthorized, "invalid access token")
+		return 0
+	default:
+		ApiError(Context, http.StatusServiceUnavailable, "unavailable")
+		return 0
+	}
+}
+
+func HandleApiAccount(Context *THttpRequestContext) {
+	AccountID := ApiAuthenticate(Context, ACCESS_TOKEN_SCOPE_READ)
+	if AccountID <= 0 {
+		return
+	}
+
+	Result, Account := Context.Backend.GetAccountSummary(AccountID)
+	switch Result {
+	case 0:
+		Account.Characters = NonNil(Account.Characters)
+		ApiWrite(Context, http.StatusOK, Account)
+	case 1:
+		ApiError(Context, http.StatusNotFound, "account not found")
+	default:
+		ApiError(Context, http.StatusServiceUnavailable, "unavailable")
+	}
+}
+
+func HandleApiAccountCharacterCreate(Context *THttpRequestContext) {
+	AccountID := ApiAuthenticate(Context, ACCESS_TOKEN_SCOPE_CHARACTERS)
+	if AccountID <= 0 {
+		return
+	}
+
+	var Request TApiCharacterCreate
+	Decoder := json.NewDecoder(http.MaxBytesReader(Context.Writer, Context.Request.Body, 4096))
+	Decoder.DisallowUnknownFields()
+	if Err := Decoder.Decode(&Request); Err != nil {
+		ApiError(Context, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	World := Context.Backend.GetWorld(strings.TrimSpace(Request.World))
+	if World == nil {
+		ApiError(Context, http.StatusBadRequest, "invalid world")
+		return
+	}
+
+	// TODO(fusion): Proper name checking, same as `HandleCharacterCreate`.
+	Name := strings.TrimSpace(Request.Name)
+	if len(Name) < 4 || len(Name) > 25 {
+		ApiError(Context, http.StatusBadRequest, "name must contain between 4 and 25 characters")
+		return
+	}
+
+	if Request.Sex != 1 && Request.Sex != 2 {
+		ApiError(Context, http.StatusBadRequest, "invalid sex")
+		return
+	}
+
+	Result := Context.Backend.CreateCharacter(World.Name, AccountID, Name, Request.Sex)
+	switch Result {
+	case 0:
+		Context.Backend.InvalidateAccountCachedData(AccountID)
+		ApiWrite(Context, http.StatusCreated,
+			TApiCharacterCreate{
+				World: World.Name,
+				Name:  Name,
+				Sex:   Request.Sex,
+			})
+	case 1:
+		ApiError(Context, http.StatusBadRequest, "invalid world")
+	case 2:
+		ApiError(Context, http.StatusNotFound, "account not found")
+	case 3:
+		ApiError(Context, http.StatusConflict, "a character with that name already exists")
+	default:
+		ApiError(Context, http.StatusServiceUnavailable, "unavailable")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// TQueryBackend
+// ==============================================================================
+// NOTE(fusion): Everything the website reads or writes goes through this
+// interface which is handed to request handlers through `THttpRequestContext`.
+// The default implementation talks to the query manager, with the caching
+// described in `query.go`, while the in-memory implementation in `backend_memory.go`
+// may be used to run the website without one during development.
+//
+// Result codes are the same for all implementations and match the ones in
+// `TQueryManagerConnection`, with negative values meaning internal errors.
+type TQueryBackend interface {
+	IsDegraded() bool
+
+	CheckAccountPassword(AccountID int, Password, IPAddress string) int
+	CreateAccount(AccountID int, Email string, Password string, Activated bool) int
+	ActivateAccount(AccountID int) int
+	RequestAccountRecovery(Email string, IPAddress string) (int, int, string)
+	RecoverAccount(Token string, Password string) (int, int)
+	ChangePassword(AccountID int, Password string) int
+	ChangeEmail(AccountID int, Email string) int
+	DeleteAccount(AccountID int) int
+	UndeleteAccount(AccountID int) int
+	CreateCharacter(World string, AccountID int, Name string, Sex int) int
+	DeleteCharacter(AccountID int, CharacterName string) int
+	UndeleteCharacter(AccountID int, CharacterName string) int
+	SetCharacterHidden(AccountID int, CharacterName string, Hidden bool) int
+
+	CreateAccessToken(AccountID int, Name string, TokenHash string, Scopes int) int
+	GetAccessTokens(AccountID int) (int, []TAccessToken)
+	RevokeAccessToken(AccountID int, TokenID int) int
+	RevokeAllAccessTokens(AccountID int) int
+	CheckAccessToken(TokenHash string) (int, int, int)
+
+	GetAccountSummary(AccountID int) (int, TAccountSummary)
+	InvalidateAccountCachedData(AccountID int)
+	GetCharacterProfile(CharacterName string) (int, TCharacterProfile)
+	InvalidateCharacterCachedData(CharacterName string)
+
+	GetWorlds() []TWorld
+	GetWorldsUpdated() time.Time
+	GetWorld(World string) *TWorld
+	GetOnlineCharacters(World string) []TOnlineCharacter
+	GetOnlineCharactersUpdated(World string) time.Time
+	GetKillStatistics(World string) []TKillStatistics
+	GetKillStatisticsUpdated(World string) time.Time
+	GetHighscores(World string, Category int) []THighscoreEntry
+	GetGuilds(World string) []TGuildSummary
+	GetGuild(GuildName string) (int, TGuild)
+	GetHouses(World string) []THouse
+	GetHouse(World string, HouseID int) *THouse
+	GetLatestDeaths(World string) []TWorldDeath
+}
+
+func InitQueryBackend() TQueryBackend {
+	g_Log.Printf("QueryBackend: %v", g_QueryBackend)
+	switch {
+	case strings.EqualFold(g_QueryBackend, "querymanager"):
+		if !InitQuery() {
+			return nil
+		}
+		return TQueryManagerBackend{}
+	case strings.EqualFold(g_QueryBackend, "memory"):
+		g_LogWarn.Print("Using the in-memory query backend which is only meant for" +
+			" development. Any changes will be lost when the server is restarted.")
+		return NewMemoryBackend()
+	default:
+		g_LogErr.Printf("Unknown query backend \"%v\"", g_QueryBackend)
+		return nil
+	}
+}
+
+// TQueryManagerBackend
+// ==============================================================================
+type TQueryManagerBackend struct{}
+
+func (Backend TQueryManagerBackend) IsDegraded() bool {
+	return IsQueryManagerDegraded()
+}
+
+func (Backend TQueryManagerBackend) CheckAccountPassword(AccountID int, Password, IPAddress string) int {
+	return CheckAccountPassword(AccountID, Password, IPAddress)
+}
+
+func (Backend TQueryManagerBackend) CreateAccount(AccountID int, Email string, Password string, Activated bool) int {
+	return CreateAccount(AccountID, Email, Password, Activated)
+}
+
+func (Backend TQueryManagerBackend) ActivateAccount(AccountID int) int {
+	return ActivateAccount(AccountID)
+}
+
+func (Backend TQueryManagerBackend) RequestAccountRecovery(Email string, IPAddress string) (int, int, string) {
+	return RequestAccountRecovery(Email, IPAddress)
+}
+
+func (Backend TQueryManagerBackend) RecoverAccount(Token string, Password string) (int, int) {
+	return RecoverAccount(Token, Password)
+}
+
+func (Backend TQueryManagerBackend) ChangePassword(AccountID int, Password string) int {
+	return ChangePassword(AccountID, Password)
+}
+
+func (Backend TQueryManagerBackend) ChangeEmail(AccountID int, Email string) int {
+	return ChangeEmail(AccountID, Email)
+}
+
+func (Backend TQueryManagerBackend) DeleteAccount(AccountID int) int {
+	return DeleteAccount(AccountID)
+}
+
+func (Backend TQueryManagerBackend) UndeleteAccount(AccountID int) int {
+	return UndeleteAccount(AccountID)
+}
+
+func (Backend TQueryManagerBackend) CreateCharacter(World string, AccountID int, Name string, Sex int) int {
+	return CreateCharacter(World, AccountID, Name, Sex)
+}
+
+func (Backend TQueryManagerBackend) DeleteCharacter(AccountID int, CharacterName string) int {
+	return DeleteCharacter(AccountID, CharacterName)
+}
+
+func (Backend TQueryManagerBackend) UndeleteCharacter(AccountID int, CharacterName string) int {
+	return UndeleteCharacter(AccountID, CharacterName)
+}
+
+func (Backend TQueryManagerBackend) SetCharacterHidden(AccountID int, CharacterName string, Hidden bool) int {
+	return SetCharacterHidden(AccountID, CharacterName, Hidden)
+}
+
+func (Backend TQueryManagerBackend) CreateAccessToken(AccountID int, Name string, TokenHash string, Scopes int) int {
+	return CreateAccessToken(AccountID, Name, TokenHash, Scopes)
+}
+
+func (Backend TQueryManagerBackend) GetAccessTokens(AccountID int) (int, []TAccessToken) {
+	return GetAccessTokens(AccountID)
+}
+
+func (Backend TQueryManagerBackend) RevokeAccessToken(AccountID int, TokenID int) int {
+	return RevokeAccessToken(AccountID, TokenID)
+}
+
+func (Backend TQueryManagerBackend) RevokeAllAccessTokens(AccountID int) int {
+	return RevokeAllAccessTokens(AccountID)
+}
+
+func (Backend TQueryManagerBackend) CheckAccessToken(TokenHash string) (int, int, int) {
+	return CheckAccessToken(TokenHash)
+}
+
+func (Backend TQueryManagerBackend) GetAccountSummary(AccountID int) (int, TAccountSummary) {
+	return GetAccountSummary(AccountID)
+}
+
+func (Backend TQueryManagerBackend) InvalidateAccountCachedData(AccountID int) {
+	InvalidateAccountCachedData(AccountID)
+}
+
+func (Backend TQueryManagerBackend) GetCharacterProfile(CharacterName string) (int, TCharacterProfile) {
+	return GetCharacterProfile(CharacterName)
+}
+
+func (Backend TQueryManagerBackend) InvalidateCharacterCachedData(CharacterName string) {
+	InvalidateCharacterCachedData(CharacterName)
+}
+
+func (Backend TQueryManagerBackend) GetWorlds() []TWorld {
+	return GetWorlds()
+}
+
+func (Backend TQueryManagerBackend) GetWorldsUpdated() time.Time {
+	return GetWorldsUpdated()
+}
+
+func (Backend TQueryManagerBackend) GetWorld(World string) *TWorld {
+	return GetWorld(World)
+}
+
+func (Backend TQueryManagerBackend) GetOnlineCharacters(World string) []TOnlineCharacter {
+	return GetOnlineCharacters(World)
+}
+
+func (Backend TQueryManagerBackend) GetOnlineCharactersUpdated(World string) time.Time {
+	return GetOnlineCharactersUpdated(World)
+}
+
+func (Backend TQueryManagerBackend) GetKillStatistics(World string) []TKillStatistics {
+	return GetKillStatistics(World)
+}
+
+func (Backend TQueryManagerBackend) GetKillStatisticsUpdated(World string) time.Time {
+	return GetKillStatisticsUpdated(World)
+}
+
+func (Backend TQueryManagerBackend) GetHighscores(World string, Category int) []THighscoreEntry {
+	return GetHighscores(World, Category)
+}
+
+func (Backend TQueryManagerBackend) GetGuilds(World string) []TGuildSummary {
+	return GetGuilds(World)
+}
+
+func (Backend TQueryManagerBackend) GetGuild(GuildName string) (int, TGuild) {
+	return GetGuild(GuildName)
+}
+
+func (Backend TQueryManagerBackend) GetHouses(World string) []THouse {
+	return GetHouses(World)
+}
+
+func (Backend TQueryManagerBackend) GetHouse(World string, HouseID int) *THouse {
+	return GetHouse(World, HouseID)
+}
+
+func (Backend TQueryManagerBackend) GetLatestDeaths(World string) []TWorldDeath {
+	return GetLatestDeaths(World)
+}
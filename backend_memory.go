@@ -0,0 +1,820 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TMemoryBackend
+// ==============================================================================
+// NOTE(fusion): This is a very simple query backend that keeps everything in
+// memory and is seeded with a few sample accounts, characters and worlds. It is
+// meant for working on the website without a query manager and game server, so
+// it only implements what the website can observe. Everything is lost when the
+// server is restarted.
+type (
+	TMemoryAccount struct {
+		AccountID     int
+		Email         string
+		PasswordHash  string
+		PremiumDays   int
+		Activated     bool
+		Deleted       bool
+		RecoveryToken string
+	}
+
+	// NOTE(fusion): Characters are only listed on the profile of the other
+	// characters in the account if `Visible` is set, which is opt-in.
+	TMemoryCharacter struct {
+		Name       string
+		World      string
+		AccountID  int
+		Sex        int
+		Guild      string
+		Rank       string
+		Title      string
+		Level      int
+		Profession string
+		Residence  string
+		LastLogin  int
+		Online     bool
+		Deleted    bool
+		Visible    bool
+		Skills     [HIGHSCORE_FISHING + 1]int
+		Deaths     []TCharacterDeath
+	}
+
+	TMemoryAccessToken struct {
+		TAccessToken
+		AccountID int
+		TokenHash string
+	}
+
+	TMemoryBackend struct {
+		Mutex          sync.Mutex
+		Started        time.Time
+		Accounts       []*TMemoryAccount
+		Characters     []*TMemoryCharacter
+		AccessTokens   []*TMemoryAccessToken
+		NextTokenID    int
+		Worlds         []TWorld
+		KillStatistics map[string][]TKillStatistics
+		Houses         map[string][]THouse
+	}
+)
+
+const (
+	MaxMemoryAccessTokens = 10
+)
+
+func HashMemoryPassword(Password string) string {
+	Hash := sha256.Sum256([]byte(Password))
+	return hex.EncodeToString(Hash[:])
+}
+
+func NewMemoryBackend() *TMemoryBackend {
+	Now := time.Now()
+	Backend := &TMemoryBackend{
+		Started: Now,
+		Worlds: []TWorld{
+			{
+				Name:                "Zanera",
+				Type:                WorldTypeString(0),
+				MaxPlayers:          1000,
+				OnlinePeak:          2,
+				OnlinePeakTimestamp: int(Now.Add(-24 * time.Hour).Unix()),
+				LastStartup:         int(Now.Add(-6 * time.Hour).Unix()),
+				LastShutdown:        int(Now.Add(-7 * time.Hour).Unix()),
+			},
+			{
+				Name:         "Antica",
+				Type:         WorldTypeString(2),
+				MaxPlayers:   1000,
+				LastStartup:  int(Now.Add(-48 * time.Hour).Unix()),
+				LastShutdown: int(Now.Add(-2 * time.Hour).Unix()),
+			},
+		},
+		KillStatistics: map[string][]TKillStatistics{
+			"zanera": {
+				{RaceName: "Rat", TimesKilled: 1523, PlayersKilled: 2},
+				{RaceName: "Orc", TimesKilled: 412, PlayersKilled: 9},
+				{RaceName: "Dragon", TimesKilled: 37, PlayersKilled: 14},
+			},
+		},
+		Houses: map[string][]THouse{
+			"zanera": {
+				{HouseID: 1, Name: "Thais Lighthouse", Town: "Thais", Size: 72, Rent: 2000,
+					Owner: "Sample Knight", PaidUntil: int(Now.Add(20 * 24 * time.Hour).Unix())},
+				{HouseID: 2, Name: "Harbour Place 1", Town: "Thais", Size: 24, Rent: 500},
+				{HouseID: 3, Name: "Magic Academy", Town: "Edron", Size: 96, Rent: 3000},
+			},
+		},
+	}
+
+	// NOTE(fusion): Sample account number is 111111 with password "tibia".
+	Backend.Accounts = append(Backend.Accounts,
+		&TMemoryAccount{
+			AccountID:    111111,
+			Email:        "sample@localhost",
+			PasswordHash: HashMemoryPassword("tibia"),
+			PremiumDays:  30,
+			Activated:    true,
+		})
+
+	Backend.Characters = append(Backend.Characters,
+		&TMemoryCharacter{
+			Name:       "Sample Knight",
+			World:      "Zanera",
+			AccountID:  111111,
+			Sex:        1,
+			Guild:      "Sample Guild",
+			Rank:       "Leader",
+			Level:      42,
+			Profession: "Elite Knight",
+			Residence:  "Thais",
+			LastLogin:  int(Now.Add(-1 * time.Hour).Unix()),
+			Online:     true,
+			Visible:    true,
+			Skills:     [HIGHSCORE_FISHING + 1]int{0, 4, 12, 18, 74, 25, 20, 70, 14},
+			Deaths: []TCharacterDeath{
+				{
+					Timestamp: int(Now.Add(-3 * time.Hour).Unix()),
+					Level:     42,
+					Killers: []TCharacterKiller{
+						{Name: "a dragon"},
+						{Name: "Sample Sorcerer", Player: true, Unjustified: true},
+					},
+				},
+			},
+		},
+		&TMemoryCharacter{
+			Name:       "Sample Sorcerer",
+			World:      "Zanera",
+			AccountID:  111111,
+			Sex:        2,
+			Guild:      "Sample Guild",
+			Rank:       "Member",
+			Title:      "Apprentice",
+			Level:      27,
+			Profession: "Sorcerer",
+			Residence:  "Edron",
+			LastLogin:  int(Now.Add(-30 * time.Minute).Unix()),
+			Online:     true,
+			Visible:    true,
+			Skills:     [HIGHSCORE_FISHING + 1]int{0, 48, 10, 10, 10, 10, 14, 15, 10},
+		},
+		&TMemoryCharacter{
+			Name:       "Sample Druid",
+			World:      "Antica",
+			AccountID:  111111,
+			Sex:        2,
+			Level:      8,
+			Profession: "Druid",
+			Residence:  "Carlin",
+			Skills:     [HIGHSCORE_FISHING + 1]int{0, 9, 10, 10, 10, 10, 10, 10, 10},
+		})
+
+	return Backend
+}
+
+func (Backend *TMemoryBackend) FindAccount(AccountID int) *TMemoryAccount {
+	for _, Account := range Backend.Accounts {
+		if Account.AccountID == AccountID {
+			return Account
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) FindAccountByEmail(Email string) *TMemoryAccount {
+	for _, Account := range Backend.Accounts {
+		if strings.EqualFold(Account.Email, Email) {
+			return Account
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) FindCharacter(CharacterName string) *TMemoryCharacter {
+	for _, Character := range Backend.Characters {
+		if strings.EqualFold(Character.Name, CharacterName) {
+			return Character
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) FindAccountCharacter(AccountID int, CharacterName string) *TMemoryCharacter {
+	Character := Backend.FindCharacter(CharacterName)
+	if Character == nil || Character.AccountID != AccountID {
+		return nil
+	}
+	return Character
+}
+
+func (Backend *TMemoryBackend) FindWorld(World string) *TWorld {
+	for Index := range Backend.Worlds {
+		if strings.EqualFold(Backend.Worlds[Index].Name, World) {
+			return &Backend.Worlds[Index]
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) IsDegraded() bool {
+	return false
+}
+
+func (Backend *TMemoryBackend) CheckAccountPassword(AccountID int, Password, IPAddress string) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	PasswordHash := HashMemoryPassword(Password)
+	if subtle.ConstantTimeCompare([]byte(PasswordHash), []byte(Account.PasswordHash)) != 1 {
+		return 2
+	}
+
+	if !Account.Activated {
+		return 7
+	}
+
+	return 0
+}
+
+func (Backend *TMemoryBackend) CreateAccount(AccountID int, Email string, Password string, Activated bool) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	if Backend.FindAccount(AccountID) != nil {
+		return 1
+	}
+
+	if Backend.FindAccountByEmail(Email) != nil {
+		return 2
+	}
+
+	Backend.Accounts = append(Backend.Accounts,
+		&TMemoryAccount{
+			AccountID:    AccountID,
+			Email:        Email,
+			PasswordHash: HashMemoryPassword(Password),
+			Activated:    Activated,
+		})
+	return 0
+}
+
+func (Backend *TMemoryBackend) ActivateAccount(AccountID int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	Account.Activated = true
+	return 0
+}
+
+func (Backend *TMemoryBackend) RequestAccountRecovery(Email string, IPAddress string) (int, int, string) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccountByEmail(Email)
+	if Account == nil {
+		return 1, 0, ""
+	}
+
+	var Token [16]byte
+	if _, Err := rand.Read(Token[:]); Err != nil {
+		g_LogErr.Printf("Failed to generate recovery token: %v", Err)
+		return -1, 0, ""
+	}
+
+	Account.RecoveryToken = hex.EncodeToString(Token[:])
+	return 0, Account.AccountID, Account.RecoveryToken
+}
+
+func (Backend *TMemoryBackend) RecoverAccount(Token string, Password string) (int, int) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	if Token == "" {
+		return 1, 0
+	}
+
+	for _, Account := range Backend.Accounts {
+		if Account.RecoveryToken == Token {
+			Account.PasswordHash = HashMemoryPassword(Password)
+			Account.RecoveryToken = ""
+			return 0, Account.AccountID
+		}
+	}
+
+	return 1, 0
+}
+
+func (Backend *TMemoryBackend) ChangePassword(AccountID int, Password string) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	Account.PasswordHash = HashMemoryPassword(Password)
+	return 0
+}
+
+func (Backend *TMemoryBackend) ChangeEmail(AccountID int, Email string) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	if Other := Backend.FindAccountByEmail(Email); Other != nil && Other != Account {
+		return 2
+	}
+
+	Account.Email = Email
+	return 0
+}
+
+func (Backend *TMemoryBackend) DeleteAccount(AccountID int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	for _, Character := range Backend.Characters {
+		if Character.AccountID == AccountID && Character.Online {
+			return 2
+		}
+	}
+
+	Account.Deleted = true
+	return 0
+}
+
+func (Backend *TMemoryBackend) UndeleteAccount(AccountID int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1
+	}
+
+	Account.Deleted = false
+	return 0
+}
+
+func (Backend *TMemoryBackend) CreateCharacter(World string, AccountID int, Name string, Sex int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	WorldData := Backend.FindWorld(World)
+	if WorldData == nil {
+		return 1
+	}
+
+	if Backend.FindAccount(AccountID) == nil {
+		return 2
+	}
+
+	if Backend.FindCharacter(Name) != nil {
+		return 3
+	}
+
+	Backend.Characters = append(Backend.Characters,
+		&TMemoryCharacter{
+			Name:      Name,
+			World:     WorldData.Name,
+			AccountID: AccountID,
+			Sex:       Sex,
+			Level:     1,
+			Residence: "Rookgaard",
+			Skills:    [HIGHSCORE_FISHING + 1]int{0, 0, 10, 10, 10, 10, 10, 10, 10},
+		})
+	return 0
+}
+
+func (Backend *TMemoryBackend) DeleteCharacter(AccountID int, CharacterName string) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Character := Backend.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return 1
+	}
+
+	if Character.Online {
+		return 2
+	}
+
+	Character.Deleted = true
+	return 0
+}
+
+func (Backend *TMemoryBackend) UndeleteCharacter(AccountID int, CharacterName string) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Character := Backend.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return 1
+	}
+
+	Character.Deleted = false
+	return 0
+}
+
+func (Backend *TMemoryBackend) SetCharacterHidden(AccountID int, CharacterName string, Hidden bool) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Character := Backend.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return 1
+	}
+
+	Character.Visible = !Hidden
+	return 0
+}
+
+func (Backend *TMemoryBackend) CreateAccessToken(AccountID int, Name string, TokenHash string, Scopes int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	if Backend.FindAccount(AccountID) == nil {
+		return 1
+	}
+
+	NumTokens := 0
+	for _, Token := range Backend.AccessTokens {
+		if Token.AccountID == AccountID {
+			NumTokens += 1
+		}
+	}
+
+	if NumTokens >= MaxMemoryAccessTokens {
+		return 2
+	}
+
+	Backend.NextTokenID += 1
+	Backend.AccessTokens = append(Backend.AccessTokens,
+		&TMemoryAccessToken{
+			TAccessToken: TAccessToken{
+				TokenID: Backend.NextTokenID,
+				Name:    Name,
+				Scopes:  Scopes,
+				Created: int(time.Now().Unix()),
+			},
+			AccountID: AccountID,
+			TokenHash: TokenHash,
+		})
+	return 0
+}
+
+func (Backend *TMemoryBackend) GetAccessTokens(AccountID int) (int, []TAccessToken) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	if Backend.FindAccount(AccountID) == nil {
+		return 1, nil
+	}
+
+	var Tokens []TAccessToken
+	for _, Token := range Backend.AccessTokens {
+		if Token.AccountID == AccountID {
+			Tokens = append(Tokens, Token.TAccessToken)
+		}
+	}
+	return 0, Tokens
+}
+
+func (Backend *TMemoryBackend) RevokeAccessToken(AccountID int, TokenID int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Index := slices.IndexFunc(Backend.AccessTokens, func(Token *TMemoryAccessToken) bool {
+		return Token.AccountID == AccountID && Token.TokenID == TokenID
+	})
+	if Index == -1 {
+		return 1
+	}
+
+	Backend.AccessTokens = slices.Delete(Backend.AccessTokens, Index, Index+1)
+	return 0
+}
+
+func (Backend *TMemoryBackend) RevokeAllAccessTokens(AccountID int) int {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Backend.AccessTokens = slices.DeleteFunc(Backend.AccessTokens, func(Token *TMemoryAccessToken) bool {
+		return Token.AccountID == AccountID
+	})
+	return 0
+}
+
+func (Backend *TMemoryBackend) CheckAccessToken(TokenHash string) (int, int, int) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	for _, Token := range Backend.AccessTokens {
+		if subtle.ConstantTimeCompare([]byte(Token.TokenHash), []byte(TokenHash)) == 1 {
+			Token.LastUsed = int(time.Now().Unix())
+			return 0, Token.AccountID, Token.Scopes
+		}
+	}
+	return 1, 0, 0
+}
+
+func (Backend *TMemoryBackend) GetAccountSummary(AccountID int) (int, TAccountSummary) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Account := Backend.FindAccount(AccountID)
+	if Account == nil {
+		return 1, TAccountSummary{}
+	}
+
+	Summary := TAccountSummary{
+		AccountID:   Account.AccountID,
+		Email:       Account.Email,
+		PremiumDays: Account.PremiumDays,
+		Deleted:     Account.Deleted,
+	}
+
+	for _, Character := range Backend.Characters {
+		if Character.AccountID == AccountID {
+			Summary.Characters = append(Summary.Characters,
+				TCharacterSummary{
+					Name:       Character.Name,
+					World:      Character.World,
+					Level:      Character.Level,
+					Profession: Character.Profession,
+					Online:     Character.Online,
+					Deleted:    Character.Deleted,
+					Hidden:     !Character.Visible,
+				})
+		}
+	}
+
+	return 0, Summary
+}
+
+func (Backend *TMemoryBackend) InvalidateAccountCachedData(AccountID int) {
+	// NOTE(fusion): Nothing is cached.
+}
+
+func (Backend *TMemoryBackend) GetCharacterProfile(CharacterName string) (int, TCharacterProfile) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Character := Backend.FindCharacter(CharacterName)
+	if Character == nil {
+		return 1, TCharacterProfile{}
+	}
+
+	Profile := TCharacterProfile{
+		Name:       Character.Name,
+		World:      Character.World,
+		Sex:        Character.Sex,
+		Guild:      Character.Guild,
+		Rank:       Character.Rank,
+		Title:      Character.Title,
+		Level:      Character.Level,
+		Profession: Character.Profession,
+		Residence:  Character.Residence,
+		LastLogin:  Character.LastLogin,
+		Online:     Character.Online,
+		Deleted:    Character.Deleted,
+		Deaths:     slices.Clone(Character.Deaths),
+	}
+
+	if Account := Backend.FindAccount(Character.AccountID); Account != nil {
+		Profile.PremiumDays = Account.PremiumDays
+	}
+
+	if Character.Visible {
+		for _, Other := range Backend.Characters {
+			if Other != Character && Other.AccountID == Character.AccountID &&
+				Other.Visible && !Other.Deleted {
+				Profile.OtherCharacters = append(Profile.OtherCharacters,
+					TOtherCharacter{
+						Name:   Other.Name,
+						World:  Other.World,
+						Online: Other.Online,
+					})
+			}
+		}
+	}
+
+	return 0, Profile
+}
+
+func (Backend *TMemoryBackend) InvalidateCharacterCachedData(CharacterName string) {
+	// NOTE(fusion): Nothing is cached.
+}
+
+func (Backend *TMemoryBackend) GetWorlds() []TWorld {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	Worlds := slices.Clone(Backend.Worlds)
+	for Index := range Worlds {
+		for _, Character := range Backend.Characters {
+			if Character.Online && strings.EqualFold(Character.World, Worlds[Index].Name) {
+				Worlds[Index].NumPlayers += 1
+			}
+		}
+	}
+	return Worlds
+}
+
+func (Backend *TMemoryBackend) GetWorldsUpdated() time.Time {
+	return time.Now()
+}
+
+func (Backend *TMemoryBackend) GetWorld(World string) *TWorld {
+	Worlds := Backend.GetWorlds()
+	for Index := range Worlds {
+		if strings.EqualFold(Worlds[Index].Name, World) {
+			return &Worlds[Index]
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) GetOnlineCharacters(World string) []TOnlineCharacter {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	var Characters []TOnlineCharacter
+	for _, Character := range Backend.Characters {
+		if Character.Online && strings.EqualFold(Character.World, World) {
+			Characters = append(Characters,
+				TOnlineCharacter{
+					Name:       Character.Name,
+					Level:      Character.Level,
+					Profession: Character.Profession,
+				})
+		}
+	}
+	return Characters
+}
+
+func (Backend *TMemoryBackend) GetOnlineCharactersUpdated(World string) time.Time {
+	return time.Now()
+}
+
+func (Backend *TMemoryBackend) GetKillStatistics(World string) []TKillStatistics {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	return slices.Clone(Backend.KillStatistics[strings.ToLower(World)])
+}
+
+func (Backend *TMemoryBackend) GetKillStatisticsUpdated(World string) time.Time {
+	return Backend.Started
+}
+
+func (Backend *TMemoryBackend) GetHighscores(World string, Category int) []THighscoreEntry {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	if Category < HIGHSCORE_LEVEL || Category > HIGHSCORE_FISHING {
+		return nil
+	}
+
+	var Characters []*TMemoryCharacter
+	for _, Character := range Backend.Characters {
+		if !Character.Deleted && strings.EqualFold(Character.World, World) {
+			Characters = append(Characters, Character)
+		}
+	}
+
+	Value := func(Character *TMemoryCharacter) int {
+		if Category == HIGHSCORE_LEVEL {
+			// NOTE(fusion): Experience needed for the character's level.
+			Level := Character.Level
+			return (50 * (Level*Level*Level - 6*Level*Level + 17*Level - 12)) / 3
+		}
+		return Character.Skills[Category]
+	}
+
+	slices.SortStableFunc(Characters, func(A, B *TMemoryCharacter) int {
+		return Value(B) - Value(A)
+	})
+
+	Entries := make([]THighscoreEntry, len(Characters))
+	for Index, Character := range Characters {
+		Entries[Index] = THighscoreEntry{
+			Rank:       Index + 1,
+			Name:       Character.Name,
+			Level:      Character.Level,
+			Profession: Character.Profession,
+			Value:      Value(Character),
+		}
+	}
+	return Entries
+}
+
+func (Backend *TMemoryBackend) GetGuilds(World string) []TGuildSummary {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	var Guilds []TGuildSummary
+	for _, Character := range Backend.Characters {
+		if Character.Guild == "" || Character.Deleted ||
+			!strings.EqualFold(Character.World, World) {
+			continue
+		}
+
+		Index := slices.IndexFunc(Guilds, func(Guild TGuildSummary) bool {
+			return Guild.Name == Character.Guild
+		})
+		if Index == -1 {
+			Guilds = append(Guilds, TGuildSummary{Name: Character.Guild})
+			Index = len(Guilds) - 1
+		}
+		Guilds[Index].NumMembers += 1
+	}
+	return Guilds
+}
+
+func (Backend *TMemoryBackend) GetGuild(GuildName string) (int, TGuild) {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	var Guild TGuild
+	for _, Character := range Backend.Characters {
+		if Character.Deleted || !strings.EqualFold(Character.Guild, GuildName) {
+			continue
+		}
+
+		if Guild.Name == "" {
+			Guild.Name = Character.Guild
+			Guild.World = Character.World
+		}
+
+		Index := slices.IndexFunc(Guild.Ranks, func(Rank TGuildRank) bool {
+			return Rank.Name == Character.Rank
+		})
+		if Index == -1 {
+			Guild.Ranks = append(Guild.Ranks, TGuildRank{Name: Character.Rank})
+			Index = len(Guild.Ranks) - 1
+		}
+
+		Guild.Ranks[Index].Members = append(Guild.Ranks[Index].Members,
+			TGuildMember{
+				Name:       Character.Name,
+				Title:      Character.Title,
+				Level:      Character.Level,
+				Profession: Character.Profession,
+				Online:     Character.Online,
+			})
+	}
+
+	if Guild.Name == "" {
+		return 1, Guild
+	}
+
+	return 0, Guild
+}
+
+func (Backend *TMemoryBackend) GetHouses(World string) []THouse {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	return slices.Clone(Backend.Houses[strings.ToLower(World)])
+}
+
+func (Backend *TMemoryBackend) GetHouse(World string, HouseID int) *THouse {
+	Houses := Backend.GetHouses(World)
+	for Index := range Houses {
+		if Houses[Index].HouseID == HouseID {
+			return &Houses[Index]
+		}
+	}
+	return nil
+}
+
+func (Backend *TMemoryBackend) GetLatestDeaths(World string) []TWorldDeath {
+	Backend.Mutex.Lock()
+	defer Backend.Mutex.Unlock()
+	var Deaths []TWorldDeath
+	for _, Character := range Backend.Characters {
+		if !strings.EqualFold(Character.World, World) {
+			continue
+		}
+
+		for _, Death := range Character.Deaths {
+			Deaths = append(Deaths,
+				TWorldDeath{
+					CharacterName: Character.Name,
+					Timestamp:     Death.Timestamp,
+					Level:         Death.Level,
+					Killers:       Death.Killers,
+				})
+		}
+	}
+
+	slices.SortFunc(Deaths, func(A, B TWorldDeath) int {
+		return B.Timestamp - A.Timestamp
+	})
+	return Deaths
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type (
+	TCacheEntry[K comparable, V any] struct {
+		Key     K
+		Value   V
+		Updated time.Time
+		Expires time.Time
+	}
+
+	TFlight[V any] struct {
+		Done  chan struct{}
+		Value V
+	}
+
+	// NOTE(fusion): Coalesces concurrent calls with the same key into a single
+	// call whose result is shared by all of them. This is used on cache misses
+	// so that a burst of requests for the same character or world results in a
+	// single query.
+	TFlightGroup[K comparable, V any] struct {
+		Mutex   sync.Mutex
+		Flights map[K]*TFlight[V]
+	}
+
+	TCacheStats struct {
+		Size      int
+		Hits      int
+		Misses    int
+		Evictions int
+	}
+
+	// NOTE(fusion): Entries are kept in a map for lookups and in a list for
+	// recency, with the most recently used entry at the front. Expired entries
+	// are NOT removed on lookup so they may still be served with `Peek` while
+	// the query manager is unreachable. They'll eventually be pushed out of the
+	// back of the list like any other entry.
+	//
+	// If set, `TTLFunc` overrides `TTL` on a per value basis, and values with
+	// a non-positive TTL are not stored at all.
+	TCache[K comparable, V any] struct {
+		Mutex     sync.Mutex
+		Capacity  int
+		TTL       time.Duration
+		TTLFunc   func(Value V) time.Duration
+		Entries   map[K]*list.Element
+		Order     *list.List
+		Hits      int
+		Misses    int
+		Evictions int
+		Flights   TFlightGroup[K, V]
+	}
+)
+
+// NOTE(fusion): `Fallback` is what waiters get if `Function` panics, and should
+// be a value that callers will treat as a failure. The zero value is usually
+// a success, which would have waiters render empty data as if it was real.
+func (Group *TFlightGroup[K, V]) Do(Key K, Fallback V, Function func() V) (Value V, Shared bool) {
+	Group.Mutex.Lock()
+	if Flight := Group.Flights[Key]; Flight != nil {
+		Group.Mutex.Unlock()
+		<-Flight.Done
+		return Flight.Value, true
+	}
+
+	if Group.Flights == nil {
+		Group.Flights = make(map[K]*TFlight[V])
+	}
+	Flight := &TFlight[V]{Done: make(chan struct{}), Value: Fallback}
+	Group.Flights[Key] = Flight
+	Group.Mutex.Unlock()
+
+	// NOTE(fusion): The flight is landed in a deferred call so that waiters are
+	// released even if `Function` panics, in which case they get `Fallback`.
+	defer func() {
+		Group.Mutex.Lock()
+		delete(Group.Flights, Key)
+		Group.Mutex.Unlock()
+		close(Flight.Done)
+	}()
+
+	Flight.Value = Function()
+	return Flight.Value, false
+}
+
+func NewCache[K comparable, V any](Capacity int, TTL time.Duration) *TCache[K, V] {
+	if Capacity <= 0 {
+		panic("cache capacity must be positive")
+	}
+
+	return &TCache[K, V]{
+		Capacity: Capacity,
+		TTL:      TTL,
+		Entries:  make(map[K]*list.Element, Capacity),
+		Order:    list.New(),
+	}
+}
+
+// NOTE(fusion): Returns the cached value only if it hasn't expired yet.
+func (Cache *TCache[K, V]) Get(Key K) (Value V, Ok bool) {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	Element := Cache.Entries[Key]
+	if Element == nil {
+		Cache.Misses += 1
+		return
+	}
+
+	Entry := Element.Value.(*TCacheEntry[K, V])
+	if time.Until(Entry.Expires) <= 0 {
+		Cache.Misses += 1
+		return
+	}
+
+	Cache.Hits += 1
+	Cache.Order.MoveToFront(Element)
+	return Entry.Value, true
+}
+
+// NOTE(fusion): Returns the cached value even if it has expired. It doesn't
+// count as a hit or miss, nor does it affect the order of eviction.
+func (Cache *TCache[K, V]) Peek(Key K) (Value V, Ok bool) {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	if Element := Cache.Entries[Key]; Element != nil {
+		Value = Element.Value.(*TCacheEntry[K, V]).Value
+		Ok = true
+	}
+	return
+}
+
+func (Cache *TCache[K, V]) Put(Key K, Value V) {
+	TTL := Cache.TTL
+	if Cache.TTLFunc != nil {
+		TTL = Cache.TTLFunc(Value)
+		if TTL <= 0 {
+			return
+		}
+	}
+
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	Now := time.Now()
+	Expires := Now.Add(TTL)
+	if Element := Cache.Entries[Key]; Element != nil {
+		Entry := Element.Value.(*TCacheEntry[K, V])
+		Entry.Value = Value
+		Entry.Updated = Now
+		Entry.Expires = Expires
+		Cache.Order.MoveToFront(Element)
+		return
+	}
+
+	for Cache.Order.Len() >= Cache.Capacity {
+		Back := Cache.Order.Back()
+		delete(Cache.Entries, Back.Value.(*TCacheEntry[K, V]).Key)
+		Cache.Order.Remove(Back)
+		Cache.Evictions += 1
+	}
+
+	Cache.Entries[Key] = Cache.Order.PushFront(
+		&TCacheEntry[K, V]{
+			Key:     Key,
+			Value:   Value,
+			Updated: Now,
+			Expires: Expires,
+		})
+}
+
+// NOTE(fusion): Returns when the entry was last stored, which is also the last
+// time its data was successfully fetched, or the zero time if there is none.
+func (Cache *TCache[K, V]) Updated(Key K) time.Time {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	if Element := Cache.Entries[Key]; Element != nil {
+		return Element.Value.(*TCacheEntry[K, V]).Updated
+	}
+	return time.Time{}
+}
+
+// NOTE(fusion): Returns how long until the entry expires, or zero if it has
+// already expired or there is none.
+func (Cache *TCache[K, V]) TimeToLive(Key K) time.Duration {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	if Element := Cache.Entries[Key]; Element != nil {
+		return max(time.Until(Element.Value.(*TCacheEntry[K, V]).Expires), 0)
+	}
+	return 0
+}
+
+func (Cache *TCache[K, V]) Remove(Key K) {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	if Element := Cache.Entries[Key]; Element != nil {
+		delete(Cache.Entries, Key)
+		Cache.Order.Remove(Element)
+	}
+}
+
+func (Cache *TCache[K, V]) Stats() TCacheStats {
+	Cache.Mutex.Lock()
+	defer Cache.Mutex.Unlock()
+	return TCacheStats{
+		Size:      Cache.Order.Len(),
+		Hits:      Cache.Hits,
+		Misses:    Cache.Misses,
+		Evictions: Cache.Evictions,
+	}
+}
+
+func LogCacheStats(Name string, Stats TCacheStats) {
+	g_Log.Printf("%v: Size=%v Hits=%v Misses=%v Evictions=%v",
+		Name, Stats.Size, Stats.Hits, Stats.Misses, Stats.Evictions)
+}
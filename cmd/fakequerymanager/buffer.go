@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// NOTE(fusion): These are trimmed down copies of the buffers in the web server's
+// `common.go`. They can't be shared because both programs are `main` packages
+// and it's not worth having a separate package just for them.
+
+// TReadBuffer
+// ==============================================================================
+type TReadBuffer struct {
+	Buffer   []byte
+	Position int
+}
+
+func (ReadBuffer *TReadBuffer) CanRead(Bytes int) bool {
+	return ReadBuffer.Position+Bytes <= len(ReadBuffer.Buffer)
+}
+
+func (ReadBuffer *TReadBuffer) Overflowed() bool {
+	return ReadBuffer.Position > len(ReadBuffer.Buffer)
+}
+
+func (ReadBuffer *TReadBuffer) ReadFlag() bool {
+	return ReadBuffer.Read8() != 0
+}
+
+func (ReadBuffer *TReadBuffer) Read8() uint8 {
+	Result := uint8(0)
+	if ReadBuffer.CanRead(1) {
+		Result = ReadBuffer.Buffer[ReadBuffer.Position]
+	}
+	ReadBuffer.Position += 1
+	return Result
+}
+
+func (ReadBuffer *TReadBuffer) Read16() uint16 {
+	Result := uint16(0)
+	if ReadBuffer.CanRead(2) {
+		Result = binary.LittleEndian.Uint16(ReadBuffer.Buffer[ReadBuffer.Position:])
+	}
+	ReadBuffer.Position += 2
+	return Result
+}
+
+func (ReadBuffer *TReadBuffer) Read32() uint32 {
+	Result := uint32(0)
+	if ReadBuffer.CanRead(4) {
+		Result = binary.LittleEndian.Uint32(ReadBuffer.Buffer[ReadBuffer.Position:])
+	}
+	ReadBuffer.Position += 4
+	return Result
+}
+
+func (ReadBuffer *TReadBuffer) ReadString() string {
+	Length := int(ReadBuffer.Read16())
+	if Length == 0xFFFF {
+		Length = int(ReadBuffer.Read32())
+	}
+
+	Result := ""
+	if ReadBuffer.CanRead(Length) {
+		Input := ReadBuffer.Buffer[ReadBuffer.Position:][:Length]
+		Result = string(Latin1ToUTF8(Input))
+	}
+	ReadBuffer.Position += Length
+	return Result
+}
+
+// TWriteBuffer
+// ==============================================================================
+// NOTE(fusion): Different from the web server's, this one grows as needed since
+// responses are built before their size is known.
+type TWriteBuffer struct {
+	Buffer []byte
+}
+
+func (WriteBuffer *TWriteBuffer) WriteFlag(Value bool) {
+	Value8 := uint8(0)
+	if Value {
+		Value8 = uint8(1)
+	}
+	WriteBuffer.Write8(Value8)
+}
+
+func (WriteBuffer *TWriteBuffer) Write8(Value uint8) {
+	WriteBuffer.Buffer = append(WriteBuffer.Buffer, Value)
+}
+
+func (WriteBuffer *TWriteBuffer) Write16(Value uint16) {
+	WriteBuffer.Buffer = binary.LittleEndian.AppendUint16(WriteBuffer.Buffer, Value)
+}
+
+func (WriteBuffer *TWriteBuffer) Write32(Value uint32) {
+	WriteBuffer.Buffer = binary.LittleEndian.AppendUint32(WriteBuffer.Buffer, Value)
+}
+
+func (WriteBuffer *TWriteBuffer) WriteString(String string) {
+	Output := UTF8ToLatin1([]byte(String))
+	Length := len(Output)
+	if Length < 0xFFFF {
+		WriteBuffer.Write16(uint16(Length))
+	} else {
+		WriteBuffer.Write16(0xFFFF)
+		WriteBuffer.Write32(uint32(Length))
+	}
+	WriteBuffer.Buffer = append(WriteBuffer.Buffer, Output...)
+}
+
+func UTF8ToLatin1(Buffer []byte) []byte {
+	Result := []byte{}
+	for len(Buffer) > 0 {
+		Codepoint, Size := utf8.DecodeRune(Buffer)
+		Buffer = Buffer[Size:]
+		if Codepoint >= 0 && Codepoint <= 0xFF && Codepoint != utf8.RuneError {
+			Result = append(Result, byte(Codepoint))
+		} else {
+			Result = append(Result, '?')
+		}
+	}
+	return Result
+}
+
+func Latin1ToUTF8(Buffer []byte) []byte {
+	Result := []byte{}
+	for ReadPos := range Buffer {
+		Result = utf8.AppendRune(Result, rune(Buffer[ReadPos]))
+	}
+	return Result
+}
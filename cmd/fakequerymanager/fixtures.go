@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NOTE(fusion): Fixtures are loaded from a JSON file whose fields match the
+// ones below. Everything is kept in memory and any changes made through queries
+// (e.g. creating accounts or characters) are lost when the server is restarted,
+// which is exactly what we want for tests.
+type (
+	TFixtures struct {
+		Worlds         []TWorld
+		Accounts       []*TAccount
+		Characters     []*TCharacter
+		AccessTokens   []*TAccessToken
+		KillStatistics map[string][]TKillStatistics
+		Houses         map[string][]THouse
+	}
+
+	TWorld struct {
+		Name                string
+		Type                int
+		MaxPlayers          int
+		OnlinePeak          int
+		OnlinePeakTimestamp int
+		LastStartup         int
+		LastShutdown        int
+	}
+
+	TAccount struct {
+		AccountID          int
+		Email              string
+		Password           string
+		PremiumDays        int
+		PendingPremiumDays int
+		Activated          bool
+		Deleted            bool
+		RecoveryToken      string `json:"-"`
+	}
+
+	// NOTE(fusion): Characters are only listed on the profile of the other
+	// characters in the account if `Visible` is set, which is opt-in.
+	TCharacter struct {
+		Name       string
+		World      string
+		AccountID  int
+		Sex        int
+		Guild      string
+		Rank       string
+		Title      string
+		Level      int
+		Profession string
+		Residence  string
+		LastLogin  int
+		Online     bool
+		Deleted    bool
+		Visible    bool
+		Skills     []int
+		Deaths     []TDeath
+	}
+
+	// NOTE(fusion): `TokenHash` is the hex encoded SHA-256 of the token, same
+	// as what the web server sends.
+	TAccessToken struct {
+		TokenID   int
+		AccountID int
+		Name      string
+		TokenHash string
+		Scopes    int
+		Created   int
+		LastUsed  int
+	}
+
+	TDeath struct {
+		Timestamp int
+		Level     int
+		Killers   []TKiller
+	}
+
+	TKiller struct {
+		Name        string
+		Player      bool
+		Unjustified bool
+	}
+
+	TKillStatistics struct {
+		RaceName      string
+		TimesKilled   int
+		PlayersKilled int
+	}
+
+	THouse struct {
+		HouseID   int
+		Name      string
+		Town      string
+		Size      int
+		Rent      int
+		Owner     string
+		PaidUntil int
+	}
+)
+
+func LoadFixtures(FileName string) (*TFixtures, error) {
+	Data, Err := os.ReadFile(FileName)
+	if Err != nil {
+		return nil, Err
+	}
+
+	Fixtures := &TFixtures{}
+	if Err := json.Unmarshal(Data, Fixtures); Err != nil {
+		return nil, fmt.Errorf("%v: %w", FileName, Err)
+	}
+
+	for _, Character := range Fixtures.Characters {
+		if Fixtures.FindAccount(Character.AccountID) == nil {
+			return nil, fmt.Errorf("%v: character \"%v\" has unknown account %v",
+				FileName, Character.Name, Character.AccountID)
+		}
+
+		if Fixtures.FindWorld(Character.World) == nil {
+			return nil, fmt.Errorf("%v: character \"%v\" has unknown world \"%v\"",
+				FileName, Character.Name, Character.World)
+		}
+	}
+
+	for _, Token := range Fixtures.AccessTokens {
+		if Fixtures.FindAccount(Token.AccountID) == nil {
+			return nil, fmt.Errorf("%v: access token %v has unknown account %v",
+				FileName, Token.TokenID, Token.AccountID)
+		}
+	}
+
+	// NOTE(fusion): Per world data is looked up with lower case world names.
+	Fixtures.KillStatistics = LowerKeys(Fixtures.KillStatistics)
+	Fixtures.Houses = LowerKeys(Fixtures.Houses)
+	return Fixtures, nil
+}
+
+func LowerKeys[V any](Map map[string]V) map[string]V {
+	Result := make(map[string]V, len(Map))
+	for Key, Value := range Map {
+		Result[strings.ToLower(Key)] = Value
+	}
+	return Result
+}
+
+func (Fixtures *TFixtures) FindWorld(World string) *TWorld {
+	for Index := range Fixtures.Worlds {
+		if strings.EqualFold(Fixtures.Worlds[Index].Name, World) {
+			return &Fixtures.Worlds[Index]
+		}
+	}
+	return nil
+}
+
+func (Fixtures *TFixtures) FindAccount(AccountID int) *TAccount {
+	for _, Account := range Fixtures.Accounts {
+		if Account.AccountID == AccountID {
+			return Account
+		}
+	}
+	return nil
+}
+
+func (Fixtures *TFixtures) FindAccountByEmail(Email string) *TAccount {
+	for _, Account := range Fixtures.Accounts {
+		if strings.EqualFold(Account.Email, Email) {
+			return Account
+		}
+	}
+	return nil
+}
+
+func (Fixtures *TFixtures) FindCharacter(CharacterName string) *TCharacter {
+	for _, Character := range Fixtures.Characters {
+		if strings.EqualFold(Character.Name, CharacterName) {
+			return Character
+		}
+	}
+	return nil
+}
+
+func (Fixtures *TFixtures) FindAccountCharacter(AccountID int, CharacterName string) *TCharacter {
+	Character := Fixtures.FindCharacter(CharacterName)
+	if Character == nil || Character.AccountID != AccountID {
+		return nil
+	}
+	return Character
+}
+
+func (Character *TCharacter) Skill(Category int) int {
+	if Category >= 0 && Category < len(Character.Skills) {
+		return Character.Skills[Category]
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// NOTE(fusion): This is a stand-in for the query manager that answers every
+// query used by the web server from a JSON fixtures file. It exists so that the
+// web server can be run end-to-end without a database or game server, be it in
+// CI or while working on the website. It is NOT a replacement for the real
+// thing: there are no bans, no premium accounting, and no persistence.
+
+const (
+	// NOTE(fusion): No query sent by the web server comes anywhere close to this.
+	MaxRequestSize = 1024 * 1024
+)
+
+var (
+	g_Log    = log.New(os.Stderr, "INFO ", log.Ldate|log.Ltime|log.Lmsgprefix)
+	g_LogErr = log.New(os.Stderr, "ERR  ", log.Ldate|log.Ltime|log.Lshortfile|log.Lmsgprefix)
+
+	g_Password string
+	g_Verbose  bool
+
+	// NOTE(fusion): Queries are handled one at a time, which is more than
+	// enough for tests and keeps handlers simple.
+	g_FixturesMutex sync.Mutex
+	g_Fixtures      *TFixtures
+)
+
+func ReadFrame(Handle net.Conn) ([]byte, error) {
+	var Help [4]byte
+	if _, Err := io.ReadFull(Handle, Help[:2]); Err != nil {
+		return nil, Err
+	}
+
+	RequestSize := int(binary.LittleEndian.Uint16(Help[:2]))
+	if RequestSize == 0xFFFF {
+		if _, Err := io.ReadFull(Handle, Help[:]); Err != nil {
+			return nil, Err
+		}
+		RequestSize = int(binary.LittleEndian.Uint32(Help[:]))
+	}
+
+	if RequestSize <= 0 || RequestSize > MaxRequestSize {
+		return nil, fmt.Errorf("invalid request size %v", RequestSize)
+	}
+
+	Request := make([]byte, RequestSize)
+	if _, Err := io.ReadFull(Handle, Request); Err != nil {
+		return nil, Err
+	}
+
+	return Request, nil
+}
+
+func WriteFrame(Handle net.Conn, Status int, Payload []byte) error {
+	ResponseSize := 1 + len(Payload)
+	Frame := make([]byte, 0, 6+ResponseSize)
+	if ResponseSize < 0xFFFF {
+		Frame = binary.LittleEndian.AppendUint16(Frame, uint16(ResponseSize))
+	} else {
+		Frame = binary.LittleEndian.AppendUint16(Frame, 0xFFFF)
+		Frame = binary.LittleEndian.AppendUint32(Frame, uint32(ResponseSize))
+	}
+	Frame = append(Frame, uint8(Status))
+	Frame = append(Frame, Payload...)
+	_, Err := Handle.Write(Frame)
+	return Err
+}
+
+func HandleConnection(Handle net.Conn) {
+	defer Handle.Close()
+	Address := Handle.RemoteAddr()
+	LoggedIn := false
+	for {
+		Request, Err := ReadFrame(Handle)
+		if Err != nil {
+			if !errors.Is(Err, io.EOF) {
+				g_LogErr.Printf("%v: %v", Address, Err)
+			}
+			return
+		}
+
+		ReadBuffer := TReadBuffer{Buffer: Request}
+		QueryType := int(ReadBuffer.Read8())
+		if g_Verbose {
+			g_Log.Printf("%v: query %v", Address, QueryType)
+		}
+
+		// NOTE(fusion): Same as the real query manager, the first query must
+		// be a login and anything else will close the connection.
+		if !LoggedIn {
+			ApplicationType := int(ReadBuffer.Read8())
+			Password := ReadBuffer.ReadString()
+			if QueryType != QUERY_LOGIN || ApplicationType != APPLICATION_TYPE_WEB ||
+				Password != g_Password {
+				g_LogErr.Printf("%v: login failed", Address)
+				WriteFrame(Handle, QUERY_STATUS_FAILED, nil)
+				return
+			}
+
+			LoggedIn = true
+			if Err := WriteFrame(Handle, QUERY_STATUS_OK, nil); Err != nil {
+				g_LogErr.Printf("%v: %v", Address, Err)
+				return
+			}
+			continue
+		}
+
+		Status := QUERY_STATUS_FAILED
+		var Response TWriteBuffer
+		if Handler := g_QueryHandlers[QueryType]; Handler != nil {
+			g_FixturesMutex.Lock()
+			Status = Handler(g_Fixtures, &ReadBuffer, &Response)
+			g_FixturesMutex.Unlock()
+			if ReadBuffer.Overflowed() {
+				g_LogErr.Printf("%v: malformed query %v", Address, QueryType)
+				Status = QUERY_STATUS_FAILED
+				Response = TWriteBuffer{}
+			}
+		} else {
+			g_LogErr.Printf("%v: unknown query %v", Address, QueryType)
+		}
+
+		if Err := WriteFrame(Handle, Status, Response.Buffer); Err != nil {
+			g_LogErr.Printf("%v: %v", Address, Err)
+			return
+		}
+	}
+}
+
+func main() {
+	Address := flag.String("addr", "127.0.0.1:7173", "address to listen on")
+	FixturesFile := flag.String("fixtures", "fixtures.json", "JSON file with the data to serve")
+	flag.StringVar(&g_Password, "password", "a6glaf0c", "password expected on login")
+	flag.BoolVar(&g_Verbose, "v", false, "log every query")
+	flag.Parse()
+
+	Fixtures, Err := LoadFixtures(*FixturesFile)
+	if Err != nil {
+		g_LogErr.Printf("Failed to load fixtures: %v", Err)
+		os.Exit(1)
+	}
+	g_Fixtures = Fixtures
+
+	Listener, Err := net.Listen("tcp4", *Address)
+	if Err != nil {
+		g_LogErr.Printf("Failed to listen to %v: %v", *Address, Err)
+		os.Exit(1)
+	}
+
+	g_Log.Printf("Fake query manager running on %v (Worlds: %v, Accounts: %v, Characters: %v)",
+		Listener.Addr(), len(Fixtures.Worlds), len(Fixtures.Accounts), len(Fixtures.Characters))
+	for {
+		Handle, Err := Listener.Accept()
+		if Err != nil {
+			g_LogErr.Printf("Failed to accept connection: %v", Err)
+			continue
+		}
+		go HandleConnection(Handle)
+	}
+}
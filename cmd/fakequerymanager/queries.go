@@ -0,0 +1,732 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	APPLICATION_TYPE_WEB = 3
+)
+
+const (
+	QUERY_STATUS_OK     = 0
+	QUERY_STATUS_ERROR  = 1
+	QUERY_STATUS_FAILED = 3
+)
+
+// NOTE(fusion): These must match the ones in the web server's `query.go`.
+const (
+	QUERY_LOGIN                    = 0
+	QUERY_CHECK_ACCOUNT_PASSWORD   = 10
+	QUERY_CREATE_ACCOUNT           = 100
+	QUERY_CREATE_CHARACTER         = 101
+	QUERY_GET_ACCOUNT_SUMMARY      = 102
+	QUERY_GET_CHARACTER_PROFILE    = 103
+	QUERY_REQUEST_ACCOUNT_RECOVERY = 104
+	QUERY_RECOVER_ACCOUNT          = 105
+	QUERY_CHANGE_PASSWORD          = 106
+	QUERY_CHANGE_EMAIL             = 107
+	QUERY_ACTIVATE_ACCOUNT         = 108
+	QUERY_DELETE_CHARACTER         = 109
+	QUERY_UNDELETE_CHARACTER       = 110
+	QUERY_DELETE_ACCOUNT           = 111
+	QUERY_UNDELETE_ACCOUNT         = 112
+	QUERY_SET_CHARACTER_HIDDEN     = 113
+	QUERY_CREATE_ACCESS_TOKEN      = 114
+	QUERY_GET_ACCESS_TOKENS        = 115
+	QUERY_REVOKE_ACCESS_TOKEN      = 116
+	QUERY_CHECK_ACCESS_TOKEN       = 117
+	QUERY_REVOKE_ALL_ACCESS_TOKENS = 118
+	QUERY_GET_WORLDS               = 150
+	QUERY_GET_ONLINE_CHARACTERS    = 151
+	QUERY_GET_KILL_STATISTICS      = 152
+	QUERY_GET_HIGHSCORES           = 153
+	QUERY_GET_GUILDS               = 154
+	QUERY_GET_GUILD                = 155
+	QUERY_GET_HOUSES               = 156
+	QUERY_GET_CHARACTER_DEATHS     = 157
+	QUERY_GET_LATEST_DEATHS        = 158
+)
+
+const (
+	HIGHSCORE_LEVEL   = 0
+	HIGHSCORE_FISHING = 8
+)
+
+const (
+	MaxAccessTokens = 10
+)
+
+type TQueryHandler func(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int
+
+var g_QueryHandlers = map[int]TQueryHandler{
+	QUERY_CHECK_ACCOUNT_PASSWORD:   HandleCheckAccountPassword,
+	QUERY_CREATE_ACCOUNT:           HandleCreateAccount,
+	QUERY_CREATE_CHARACTER:         HandleCreateCharacter,
+	QUERY_GET_ACCOUNT_SUMMARY:      HandleGetAccountSummary,
+	QUERY_GET_CHARACTER_PROFILE:    HandleGetCharacterProfile,
+	QUERY_REQUEST_ACCOUNT_RECOVERY: HandleRequestAccountRecovery,
+	QUERY_RECOVER_ACCOUNT:          HandleRecoverAccount,
+	QUERY_CHANGE_PASSWORD:          HandleChangePassword,
+	QUERY_CHANGE_EMAIL:             HandleChangeEmail,
+	QUERY_ACTIVATE_ACCOUNT:         HandleActivateAccount,
+	QUERY_DELETE_CHARACTER:         HandleDeleteCharacter,
+	QUERY_UNDELETE_CHARACTER:       HandleUndeleteCharacter,
+	QUERY_DELETE_ACCOUNT:           HandleDeleteAccount,
+	QUERY_UNDELETE_ACCOUNT:         HandleUndeleteAccount,
+	QUERY_SET_CHARACTER_HIDDEN:     HandleSetCharacterHidden,
+	QUERY_CREATE_ACCESS_TOKEN:      HandleCreateAccessToken,
+	QUERY_GET_ACCESS_TOKENS:        HandleGetAccessTokens,
+	QUERY_REVOKE_ACCESS_TOKEN:      HandleRevokeAccessToken,
+	QUERY_CHECK_ACCESS_TOKEN:       HandleCheckAccessToken,
+	QUERY_REVOKE_ALL_ACCESS_TOKENS: HandleRevokeAllAccessTokens,
+	QUERY_GET_WORLDS:               HandleGetWorlds,
+	QUERY_GET_ONLINE_CHARACTERS:    HandleGetOnlineCharacters,
+	QUERY_GET_KILL_STATISTICS:      HandleGetKillStatistics,
+	QUERY_GET_HIGHSCORES:           HandleGetHighscores,
+	QUERY_GET_GUILDS:               HandleGetGuilds,
+	QUERY_GET_GUILD:                HandleGetGuild,
+	QUERY_GET_HOUSES:               HandleGetHouses,
+	QUERY_GET_CHARACTER_DEATHS:     HandleGetCharacterDeaths,
+	QUERY_GET_LATEST_DEATHS:        HandleGetLatestDeaths,
+}
+
+func QueryError(Response *TWriteBuffer, ErrorCode int) int {
+	Response.Write8(uint8(ErrorCode))
+	return QUERY_STATUS_ERROR
+}
+
+func WriteKillers(Response *TWriteBuffer, Killers []TKiller) {
+	Response.Write8(uint8(len(Killers)))
+	for _, Killer := range Killers {
+		Response.WriteString(Killer.Name)
+		Response.WriteFlag(Killer.Player)
+		Response.WriteFlag(Killer.Unjustified)
+	}
+}
+
+func HandleCheckAccountPassword(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Password := Request.ReadString()
+	_ = Request.ReadString() // IPAddress
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	if Account.Password != Password {
+		return QueryError(Response, 2)
+	}
+
+	if !Account.Activated {
+		return QueryError(Response, 7)
+	}
+
+	return QUERY_STATUS_OK
+}
+
+func HandleCreateAccount(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Email := Request.ReadString()
+	Password := Request.ReadString()
+	Activated := Request.ReadFlag()
+	if Fixtures.FindAccount(AccountID) != nil {
+		return QueryError(Response, 1)
+	}
+
+	if Fixtures.FindAccountByEmail(Email) != nil {
+		return QueryError(Response, 2)
+	}
+
+	Fixtures.Accounts = append(Fixtures.Accounts,
+		&TAccount{
+			AccountID: AccountID,
+			Email:     Email,
+			Password:  Password,
+			Activated: Activated,
+		})
+	return QUERY_STATUS_OK
+}
+
+func HandleCreateCharacter(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	AccountID := int(Request.Read32())
+	Name := Request.ReadString()
+	Sex := int(Request.Read8())
+	WorldData := Fixtures.FindWorld(World)
+	if WorldData == nil {
+		return QueryError(Response, 1)
+	}
+
+	if Fixtures.FindAccount(AccountID) == nil {
+		return QueryError(Response, 2)
+	}
+
+	if Fixtures.FindCharacter(Name) != nil {
+		return QueryError(Response, 3)
+	}
+
+	Fixtures.Characters = append(Fixtures.Characters,
+		&TCharacter{
+			Name:      Name,
+			World:     WorldData.Name,
+			AccountID: AccountID,
+			Sex:       Sex,
+			Level:     1,
+			Residence: "Rookgaard",
+		})
+	return QUERY_STATUS_OK
+}
+
+func HandleGetAccountSummary(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	var Characters []*TCharacter
+	for _, Character := range Fixtures.Characters {
+		if Character.AccountID == AccountID {
+			Characters = append(Characters, Character)
+		}
+	}
+
+	Response.WriteString(Account.Email)
+	Response.Write16(uint16(Account.PremiumDays))
+	Response.Write16(uint16(Account.PendingPremiumDays))
+	Response.WriteFlag(Account.Deleted)
+	Response.Write8(uint8(len(Characters)))
+	for _, Character := range Characters {
+		Response.WriteString(Character.Name)
+		Response.WriteString(Character.World)
+		Response.Write16(uint16(Character.Level))
+		Response.WriteString(Character.Profession)
+		Response.WriteFlag(Character.Online)
+		Response.WriteFlag(Character.Deleted)
+		Response.WriteFlag(!Character.Visible)
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetCharacterProfile(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	CharacterName := Request.ReadString()
+	Character := Fixtures.FindCharacter(CharacterName)
+	if Character == nil {
+		return QueryError(Response, 1)
+	}
+
+	PremiumDays := 0
+	if Account := Fixtures.FindAccount(Character.AccountID); Account != nil {
+		PremiumDays = Account.PremiumDays
+	}
+
+	var OtherCharacters []*TCharacter
+	if Character.Visible {
+		for _, Other := range Fixtures.Characters {
+			if Other != Character && Other.AccountID == Character.AccountID &&
+				Other.Visible && !Other.Deleted {
+				OtherCharacters = append(OtherCharacters, Other)
+			}
+		}
+	}
+
+	Response.WriteString(Character.Name)
+	Response.WriteString(Character.World)
+	Response.Write8(uint8(Character.Sex))
+	Response.WriteString(Character.Guild)
+	Response.WriteString(Character.Rank)
+	Response.WriteString(Character.Title)
+	Response.Write16(uint16(Character.Level))
+	Response.WriteString(Character.Profession)
+	Response.WriteString(Character.Residence)
+	Response.Write32(uint32(Character.LastLogin))
+	Response.Write16(uint16(PremiumDays))
+	Response.WriteFlag(Character.Online)
+	Response.WriteFlag(Character.Deleted)
+	Response.Write8(uint8(len(OtherCharacters)))
+	for _, Other := range OtherCharacters {
+		Response.WriteString(Other.Name)
+		Response.WriteString(Other.World)
+		Response.WriteFlag(Other.Online)
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleRequestAccountRecovery(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	Email := Request.ReadString()
+	_ = Request.ReadString() // IPAddress
+	Account := Fixtures.FindAccountByEmail(Email)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	var Token [16]byte
+	if _, Err := rand.Read(Token[:]); Err != nil {
+		g_LogErr.Printf("Failed to generate recovery token: %v", Err)
+		return QUERY_STATUS_FAILED
+	}
+
+	Account.RecoveryToken = hex.EncodeToString(Token[:])
+	Response.Write32(uint32(Account.AccountID))
+	Response.WriteString(Account.RecoveryToken)
+	return QUERY_STATUS_OK
+}
+
+func HandleRecoverAccount(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	Token := Request.ReadString()
+	Password := Request.ReadString()
+	if Token != "" {
+		for _, Account := range Fixtures.Accounts {
+			if Account.RecoveryToken == Token {
+				Account.Password = Password
+				Account.RecoveryToken = ""
+				Response.Write32(uint32(Account.AccountID))
+				return QUERY_STATUS_OK
+			}
+		}
+	}
+	return QueryError(Response, 1)
+}
+
+func HandleChangePassword(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Password := Request.ReadString()
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	Account.Password = Password
+	return QUERY_STATUS_OK
+}
+
+func HandleChangeEmail(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Email := Request.ReadString()
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	if Other := Fixtures.FindAccountByEmail(Email); Other != nil && Other != Account {
+		return QueryError(Response, 2)
+	}
+
+	Account.Email = Email
+	return QUERY_STATUS_OK
+}
+
+func HandleActivateAccount(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	Account.Activated = true
+	return QUERY_STATUS_OK
+}
+
+func HandleDeleteCharacter(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	CharacterName := Request.ReadString()
+	Character := Fixtures.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return QueryError(Response, 1)
+	}
+
+	if Character.Online {
+		return QueryError(Response, 2)
+	}
+
+	Character.Deleted = true
+	return QUERY_STATUS_OK
+}
+
+func HandleUndeleteCharacter(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	CharacterName := Request.ReadString()
+	Character := Fixtures.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return QueryError(Response, 1)
+	}
+
+	Character.Deleted = false
+	return QUERY_STATUS_OK
+}
+
+func HandleDeleteAccount(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	for _, Character := range Fixtures.Characters {
+		if Character.AccountID == AccountID && Character.Online {
+			return QueryError(Response, 2)
+		}
+	}
+
+	Account.Deleted = true
+	return QUERY_STATUS_OK
+}
+
+func HandleUndeleteAccount(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Account := Fixtures.FindAccount(AccountID)
+	if Account == nil {
+		return QueryError(Response, 1)
+	}
+
+	Account.Deleted = false
+	return QUERY_STATUS_OK
+}
+
+func HandleSetCharacterHidden(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	CharacterName := Request.ReadString()
+	Hidden := Request.ReadFlag()
+	Character := Fixtures.FindAccountCharacter(AccountID, CharacterName)
+	if Character == nil {
+		return QueryError(Response, 1)
+	}
+
+	Character.Visible = !Hidden
+	return QUERY_STATUS_OK
+}
+
+func HandleCreateAccessToken(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Name := Request.ReadString()
+	TokenHash := Request.ReadString()
+	Scopes := int(Request.Read8())
+	if Fixtures.FindAccount(AccountID) == nil {
+		return QueryError(Response, 1)
+	}
+
+	NumTokens := 0
+	NextTokenID := 1
+	for _, Token := range Fixtures.AccessTokens {
+		if Token.AccountID == AccountID {
+			NumTokens += 1
+		}
+		NextTokenID = max(NextTokenID, Token.TokenID+1)
+	}
+
+	if NumTokens >= MaxAccessTokens {
+		return QueryError(Response, 2)
+	}
+
+	Fixtures.AccessTokens = append(Fixtures.AccessTokens,
+		&TAccessToken{
+			TokenID:   NextTokenID,
+			AccountID: AccountID,
+			Name:      Name,
+			TokenHash: TokenHash,
+			Scopes:    Scopes,
+			Created:   int(time.Now().Unix()),
+		})
+	return QUERY_STATUS_OK
+}
+
+func HandleGetAccessTokens(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	if Fixtures.FindAccount(AccountID) == nil {
+		return QueryError(Response, 1)
+	}
+
+	var Tokens []*TAccessToken
+	for _, Token := range Fixtures.AccessTokens {
+		if Token.AccountID == AccountID {
+			Tokens = append(Tokens, Token)
+		}
+	}
+
+	Response.Write8(uint8(len(Tokens)))
+	for _, Token := range Tokens {
+		Response.Write32(uint32(Token.TokenID))
+		Response.WriteString(Token.Name)
+		Response.Write8(uint8(Token.Scopes))
+		Response.Write32(uint32(Token.Created))
+		Response.Write32(uint32(Token.LastUsed))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleRevokeAccessToken(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	TokenID := int(Request.Read32())
+	Index := slices.IndexFunc(Fixtures.AccessTokens, func(Token *TAccessToken) bool {
+		return Token.AccountID == AccountID && Token.TokenID == TokenID
+	})
+	if Index == -1 {
+		return QueryError(Response, 1)
+	}
+
+	Fixtures.AccessTokens = slices.Delete(Fixtures.AccessTokens, Index, Index+1)
+	return QUERY_STATUS_OK
+}
+
+func HandleRevokeAllAccessTokens(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	AccountID := int(Request.Read32())
+	Fixtures.AccessTokens = slices.DeleteFunc(Fixtures.AccessTokens, func(Token *TAccessToken) bool {
+		return Token.AccountID == AccountID
+	})
+	return QUERY_STATUS_OK
+}
+
+func HandleCheckAccessToken(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	TokenHash := Request.ReadString()
+	for _, Token := range Fixtures.AccessTokens {
+		if strings.EqualFold(Token.TokenHash, TokenHash) {
+			Token.LastUsed = int(time.Now().Unix())
+			Response.Write32(uint32(Token.AccountID))
+			Response.Write8(uint8(Token.Scopes))
+			return QUERY_STATUS_OK
+		}
+	}
+	return QueryError(Response, 1)
+}
+
+func HandleGetWorlds(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	Response.Write8(uint8(len(Fixtures.Worlds)))
+	for _, World := range Fixtures.Worlds {
+		NumPlayers := 0
+		for _, Character := range Fixtures.Characters {
+			if Character.Online && strings.EqualFold(Character.World, World.Name) {
+				NumPlayers += 1
+			}
+		}
+
+		Response.WriteString(World.Name)
+		Response.Write8(uint8(World.Type))
+		Response.Write16(uint16(NumPlayers))
+		Response.Write16(uint16(World.MaxPlayers))
+		Response.Write16(uint16(max(World.OnlinePeak, NumPlayers)))
+		Response.Write32(uint32(World.OnlinePeakTimestamp))
+		Response.Write32(uint32(World.LastStartup))
+		Response.Write32(uint32(World.LastShutdown))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetOnlineCharacters(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	var Characters []*TCharacter
+	for _, Character := range Fixtures.Characters {
+		if Character.Online && strings.EqualFold(Character.World, World) {
+			Characters = append(Characters, Character)
+		}
+	}
+
+	Response.Write16(uint16(len(Characters)))
+	for _, Character := range Characters {
+		Response.WriteString(Character.Name)
+		Response.Write16(uint16(Character.Level))
+		Response.WriteString(Character.Profession)
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetKillStatistics(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	Stats := Fixtures.KillStatistics[strings.ToLower(World)]
+	Response.Write16(uint16(len(Stats)))
+	for _, Entry := range Stats {
+		Response.WriteString(Entry.RaceName)
+		Response.Write32(uint32(Entry.PlayersKilled))
+		Response.Write32(uint32(Entry.TimesKilled))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetHighscores(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	Category := int(Request.Read8())
+	MaxEntries := int(Request.Read16())
+	if Category < HIGHSCORE_LEVEL || Category > HIGHSCORE_FISHING {
+		return QUERY_STATUS_FAILED
+	}
+
+	Value := func(Character *TCharacter) int {
+		if Category == HIGHSCORE_LEVEL {
+			// NOTE(fusion): Experience needed for the character's level.
+			Level := Character.Level
+			return (50 * (Level*Level*Level - 6*Level*Level + 17*Level - 12)) / 3
+		}
+		return Character.Skill(Category)
+	}
+
+	var Characters []*TCharacter
+	for _, Character := range Fixtures.Characters {
+		if !Character.Deleted && strings.EqualFold(Character.World, World) {
+			Characters = append(Characters, Character)
+		}
+	}
+
+	slices.SortStableFunc(Characters, func(A, B *TCharacter) int {
+		return Value(B) - Value(A)
+	})
+
+	if len(Characters) > MaxEntries {
+		Characters = Characters[:MaxEntries]
+	}
+
+	Response.Write16(uint16(len(Characters)))
+	for _, Character := range Characters {
+		Response.WriteString(Character.Name)
+		Response.Write16(uint16(Character.Level))
+		Response.WriteString(Character.Profession)
+		Response.Write32(uint32(Value(Character)))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetGuilds(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	var Guilds []string
+	NumMembers := make(map[string]int)
+	for _, Character := range Fixtures.Characters {
+		if Character.Guild == "" || Character.Deleted ||
+			!strings.EqualFold(Character.World, World) {
+			continue
+		}
+
+		if NumMembers[Character.Guild] == 0 {
+			Guilds = append(Guilds, Character.Guild)
+		}
+		NumMembers[Character.Guild] += 1
+	}
+
+	Response.Write16(uint16(len(Guilds)))
+	for _, Guild := range Guilds {
+		Response.WriteString(Guild)
+		Response.Write16(uint16(NumMembers[Guild]))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetGuild(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	GuildName := Request.ReadString()
+	var Ranks []string
+	Members := make(map[string][]*TCharacter)
+	var Guild, World string
+	for _, Character := range Fixtures.Characters {
+		if Character.Deleted || !strings.EqualFold(Character.Guild, GuildName) {
+			continue
+		}
+
+		if Guild == "" {
+			Guild = Character.Guild
+			World = Character.World
+		}
+
+		if len(Members[Character.Rank]) == 0 {
+			Ranks = append(Ranks, Character.Rank)
+		}
+		Members[Character.Rank] = append(Members[Character.Rank], Character)
+	}
+
+	if Guild == "" {
+		return QueryError(Response, 1)
+	}
+
+	Response.WriteString(Guild)
+	Response.WriteString(World)
+	Response.Write8(uint8(len(Ranks)))
+	for _, Rank := range Ranks {
+		Response.WriteString(Rank)
+		Response.Write16(uint16(len(Members[Rank])))
+		for _, Member := range Members[Rank] {
+			Response.WriteString(Member.Name)
+			Response.WriteString(Member.Title)
+			Response.Write16(uint16(Member.Level))
+			Response.WriteString(Member.Profession)
+			Response.WriteFlag(Member.Online)
+		}
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetHouses(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	World := Request.ReadString()
+	Houses := Fixtures.Houses[strings.ToLower(World)]
+	Response.Write16(uint16(len(Houses)))
+	for _, House := range Houses {
+		Response.Write16(uint16(House.HouseID))
+		Response.WriteString(House.Name)
+		Response.WriteString(House.Town)
+		Response.Write16(uint16(House.Size))
+		Response.Write32(uint32(House.Rent))
+		Response.WriteString(House.Owner)
+		Response.Write32(uint32(House.PaidUntil))
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetCharacterDeaths(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	CharacterName := Request.ReadString()
+	MaxDeaths := int(Request.Read8())
+	Character := Fixtures.FindCharacter(CharacterName)
+	if Character == nil {
+		return QueryError(Response, 1)
+	}
+
+	Deaths := slices.Clone(Character.Deaths)
+	slices.SortFunc(Deaths, func(A, B TDeath) int {
+		return B.Timestamp - A.Timestamp
+	})
+
+	if len(Deaths) > MaxDeaths {
+		Deaths = Deaths[:MaxDeaths]
+	}
+
+	Response.Write8(uint8(len(Deaths)))
+	for _, Death := range Deaths {
+		Response.Write32(uint32(Death.Timestamp))
+		Response.Write16(uint16(Death.Level))
+		WriteKillers(Response, Death.Killers)
+	}
+	return QUERY_STATUS_OK
+}
+
+func HandleGetLatestDeaths(Fixtures *TFixtures, Request *TReadBuffer, Response *TWriteBuffer) int {
+	type TWorldDeath struct {
+		CharacterName string
+		TDeath
+	}
+
+	World := Request.ReadString()
+	MaxDeaths := int(Request.Read16())
+	var Deaths []TWorldDeath
+	for _, Character := range Fixtures.Characters {
+		if strings.EqualFold(Character.World, World) {
+			for _, Death := range Character.Deaths {
+				Deaths = append(Deaths, TWorldDeath{Character.Name, Death})
+			}
+		}
+	}
+
+	slices.SortFunc(Deaths, func(A, B TWorldDeath) int {
+		return B.Timestamp - A.Timestamp
+	})
+
+	if len(Deaths) > MaxDeaths {
+		Deaths = Deaths[:MaxDeaths]
+	}
+
+	Response.Write16(uint16(len(Deaths)))
+	for _, Death := range Deaths {
+		Response.WriteString(Death.CharacterName)
+		Response.Write32(uint32(Death.Timestamp))
+		Response.Write16(uint16(Death.Level))
+		WriteKillers(Response, Death.Killers)
+	}
+	return QUERY_STATUS_OK
+}
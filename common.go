@@ -274,7 +274,7 @@ func ParseSize(String string) int {
 }
 
 func ParseString(String string) string {
-	if len(String) > 2 {
+	if len(String) >= 2 {
 		if String[0] == '"' && String[len(String)-1] == '"' ||
 			String[0] == '\'' && String[len(String)-1] == '\'' ||
 			String[0] == '`' && String[len(String)-1] == '`' {
@@ -379,6 +379,65 @@ func WorldTypeString(Type int) string {
 	}
 }
 
+func HighscoreCategoryKey(Category int) string {
+	switch Category {
+	case HIGHSCORE_LEVEL:
+		return "level"
+	case HIGHSCORE_MAGIC:
+		return "magic"
+	case HIGHSCORE_FIST:
+		return "fist"
+	case HIGHSCORE_CLUB:
+		return "club"
+	case HIGHSCORE_SWORD:
+		return "sword"
+	case HIGHSCORE_AXE:
+		return "axe"
+	case HIGHSCORE_DISTANCE:
+		return "distance"
+	case HIGHSCORE_SHIELDING:
+		return "shielding"
+	case HIGHSCORE_FISHING:
+		return "fishing"
+	default:
+		return ""
+	}
+}
+
+func ParseHighscoreCategory(String string) int {
+	for Category := HIGHSCORE_LEVEL; Category <= HIGHSCORE_FISHING; Category += 1 {
+		if strings.EqualFold(String, HighscoreCategoryKey(Category)) {
+			return Category
+		}
+	}
+	return -1
+}
+
+func HighscoreCategoryString(Category int) string {
+	switch Category {
+	case HIGHSCORE_LEVEL:
+		return "Level"
+	case HIGHSCORE_MAGIC:
+		return "Magic Level"
+	case HIGHSCORE_FIST:
+		return "Fist Fighting"
+	case HIGHSCORE_CLUB:
+		return "Club Fighting"
+	case HIGHSCORE_SWORD:
+		return "Sword Fighting"
+	case HIGHSCORE_AXE:
+		return "Axe Fighting"
+	case HIGHSCORE_DISTANCE:
+		return "Distance Fighting"
+	case HIGHSCORE_SHIELDING:
+		return "Shielding"
+	case HIGHSCORE_FISHING:
+		return "Fishing"
+	default:
+		return "Unknown"
+	}
+}
+
 func FormatTimestamp(Timestamp int) string {
 	String := "Never"
 	if Timestamp > 0 {
@@ -397,6 +456,26 @@ func FormatDurationSince(Timestamp int) string {
 	return String
 }
 
+func FormatTimeSince(Time time.Time) string {
+	if Time.IsZero() {
+		return "never"
+	}
+
+	Duration := time.Since(Time)
+	switch {
+	case Duration < time.Minute:
+		return "just now"
+	case Duration < 2*time.Minute:
+		return "1 minute ago"
+	case Duration < time.Hour:
+		return strconv.Itoa(int(Duration/time.Minute)) + " minutes ago"
+	case Duration < 2*time.Hour:
+		return "1 hour ago"
+	default:
+		return strconv.Itoa(int(Duration/time.Hour)) + " hours ago"
+	}
+}
+
 func UTF8FindNextLeadingByte(Buffer []byte) int {
 	Offset := 0
 	for Offset < len(Buffer) {
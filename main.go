@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"slices"
@@ -27,6 +29,7 @@ type (
 	THttpRouter struct {
 		Routes   []THttpRoute
 		NotFound THttpHandler
+		Backend  TQueryBackend
 	}
 
 	THttpRequestContext struct {
@@ -37,6 +40,8 @@ type (
 		IPAddress string
 		SessionID []byte
 		AccountID int
+		CSRFToken string
+		Backend   TQueryBackend
 	}
 )
 
@@ -46,6 +51,28 @@ var (
 	g_HttpsPort     int    = 443
 	g_HttpsCertFile string = ""
 	g_HttpsKeyFile  string = ""
+	g_WebsiteURL    string = "http://localhost"
+
+	// API Config
+	g_ApiAllowedOrigins string = ""
+
+	// Token Config
+	g_TokenSecret string = ""
+
+	// Account Config
+	g_RequireEmailActivation bool = false
+
+	// Rate Limit Config
+	g_LoginBurstPerIP             = 10
+	g_LoginIntervalPerIP          = 30 * time.Second
+	g_LoginBurstPerAccount        = 5
+	g_LoginIntervalPerAccount     = 1 * time.Minute
+	g_AccountCreateBurstPerIP     = 3
+	g_AccountCreateIntervalPerIP  = 10 * time.Minute
+	g_AccountRecoverBurstPerIP    = 3
+	g_AccountRecoverIntervalPerIP = 10 * time.Minute
+	g_ApiTokenBurstPerIP          = 30
+	g_ApiTokenIntervalPerIP       = 1 * time.Second
 
 	// SMTP Config
 	g_SmtpHost     string = "smtp.domain.com"
@@ -54,16 +81,28 @@ var (
 	g_SmtpPassword string = ""
 	g_SmtpSender   string = "support@domain.com"
 
+	// Query Backend Config
+	g_QueryBackend string = "querymanager"
+
 	// Query Manager Config
-	g_QueryManagerHost     string = "localhost"
-	g_QueryManagerPort     int    = 7174
-	g_QueryManagerPassword string = ""
+	g_QueryManagerHost              string = "localhost"
+	g_QueryManagerPort              int    = 7174
+	g_QueryManagerPassword          string = ""
+	g_QueryManagerMaxConnections    int    = 4
+	g_QueryManagerTimeout                  = 10 * time.Second
+	g_QueryManagerKeepaliveInterval        = 30 * time.Second
 
 	// Query Manager Cache Config
-	g_MaxCachedAccounts        = 4096
-	g_MaxCachedCharacters      = 4096
-	g_CharacterRefreshInterval = 15 * time.Minute
-	g_WorldRefreshInterval     = 15 * time.Minute
+	g_MaxCachedAccounts         = 4096
+	g_MaxCachedCharacters       = 4096
+	g_AccountCacheTTL           = 15 * time.Minute
+	g_CharacterCacheTTL         = 15 * time.Minute
+	g_CharacterNotFoundCacheTTL = 1 * time.Minute
+	g_CharacterErrorCacheTTL    = 0 * time.Second
+	g_WorldCacheTTL             = 15 * time.Minute
+	g_OnlineCharactersCacheTTL  = 5 * time.Minute
+	g_KillStatisticsCacheTTL    = 15 * time.Minute
+	g_WorldRefresherInterval    = 1 * time.Minute
 
 	// Loggers
 	g_Log     = log.New(os.Stderr, "INFO ", log.Ldate|log.Ltime|log.Lmsgprefix)
@@ -80,6 +119,34 @@ func WebKVCallback(Key string, Value string) {
 		g_HttpsCertFile = ParseString(Value)
 	} else if strings.EqualFold(Key, "HttpsKeyFile") {
 		g_HttpsKeyFile = ParseString(Value)
+	} else if strings.EqualFold(Key, "WebsiteURL") {
+		g_WebsiteURL = strings.TrimSuffix(ParseString(Value), "/")
+	} else if strings.EqualFold(Key, "ApiAllowedOrigins") {
+		g_ApiAllowedOrigins = ParseString(Value)
+	} else if strings.EqualFold(Key, "TokenSecret") {
+		g_TokenSecret = ParseString(Value)
+	} else if strings.EqualFold(Key, "RequireEmailActivation") {
+		g_RequireEmailActivation = ParseBoolean(Value)
+	} else if strings.EqualFold(Key, "LoginBurstPerIP") {
+		g_LoginBurstPerIP = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "LoginIntervalPerIP") {
+		g_LoginIntervalPerIP = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "LoginBurstPerAccount") {
+		g_LoginBurstPerAccount = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "LoginIntervalPerAccount") {
+		g_LoginIntervalPerAccount = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "AccountCreateBurstPerIP") {
+		g_AccountCreateBurstPerIP = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "AccountCreateIntervalPerIP") {
+		g_AccountCreateIntervalPerIP = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "AccountRecoverBurstPerIP") {
+		g_AccountRecoverBurstPerIP = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "AccountRecoverIntervalPerIP") {
+		g_AccountRecoverIntervalPerIP = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "ApiTokenBurstPerIP") {
+		g_ApiTokenBurstPerIP = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "ApiTokenIntervalPerIP") {
+		g_ApiTokenIntervalPerIP = ParseDuration(Value)
 	} else if strings.EqualFold(Key, "SmtpHost") {
 		g_SmtpHost = ParseString(Value)
 	} else if strings.EqualFold(Key, "SmtpPort") {
@@ -90,20 +157,56 @@ func WebKVCallback(Key string, Value string) {
 		g_SmtpPassword = ParseString(Value)
 	} else if strings.EqualFold(Key, "SmtpSender") {
 		g_SmtpSender = ParseString(Value)
+	} else if strings.EqualFold(Key, "QueryBackend") {
+		g_QueryBackend = ParseString(Value)
 	} else if strings.EqualFold(Key, "QueryManagerHost") {
 		g_QueryManagerHost = ParseString(Value)
 	} else if strings.EqualFold(Key, "QueryManagerPort") {
 		g_QueryManagerPort = ParseInteger(Value)
 	} else if strings.EqualFold(Key, "QueryManagerPassword") {
 		g_QueryManagerPassword = ParseString(Value)
+	} else if strings.EqualFold(Key, "QueryManagerMaxConnections") {
+		g_QueryManagerMaxConnections = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "QueryManagerTimeout") {
+		g_QueryManagerTimeout = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "QueryManagerKeepaliveInterval") {
+		g_QueryManagerKeepaliveInterval = ParseDuration(Value)
 	} else if strings.EqualFold(Key, "MaxCachedAccounts") {
 		g_MaxCachedAccounts = ParseInteger(Value)
 	} else if strings.EqualFold(Key, "MaxCachedCharacters") {
 		g_MaxCachedCharacters = ParseInteger(Value)
+	} else if strings.EqualFold(Key, "AccountCacheTTL") {
+		g_AccountCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "CharacterCacheTTL") {
+		g_CharacterCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "CharacterNotFoundCacheTTL") {
+		g_CharacterNotFoundCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "CharacterErrorCacheTTL") {
+		g_CharacterErrorCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "WorldCacheTTL") {
+		g_WorldCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "OnlineCharactersCacheTTL") {
+		g_OnlineCharactersCacheTTL = ParseDuration(Value)
+	} else if strings.EqualFold(Key, "KillStatisticsCacheTTL") {
+		g_KillStatisticsCacheTTL = ParseDuration(Value)
 	} else if strings.EqualFold(Key, "CharacterRefreshInterval") {
-		g_CharacterRefreshInterval = ParseDuration(Value)
+		// NOTE(fusion): Deprecated in favor of separate cache TTLs. It used to
+		// control both account and character data so it still does.
+		g_LogWarn.Print("\"CharacterRefreshInterval\" is deprecated, use" +
+			" \"AccountCacheTTL\" and \"CharacterCacheTTL\" instead")
+		g_AccountCacheTTL = ParseDuration(Value)
+		g_CharacterCacheTTL = g_AccountCacheTTL
 	} else if strings.EqualFold(Key, "WorldRefreshInterval") {
-		g_WorldRefreshInterval = ParseDuration(Value)
+		// NOTE(fusion): Deprecated in favor of separate cache TTLs. It used to
+		// control how long world data was kept, NOT how often the background
+		// refresher runs, which is `WorldRefresherInterval`.
+		g_LogWarn.Print("\"WorldRefreshInterval\" is deprecated, use \"WorldCacheTTL\"," +
+			" \"OnlineCharactersCacheTTL\" and \"KillStatisticsCacheTTL\" instead")
+		g_WorldCacheTTL = ParseDuration(Value)
+		g_OnlineCharactersCacheTTL = g_WorldCacheTTL
+		g_KillStatisticsCacheTTL = g_WorldCacheTTL
+	} else if strings.EqualFold(Key, "WorldRefresherInterval") {
+		g_WorldRefresherInterval = ParseDuration(Value)
 	} else {
 		g_LogWarn.Printf("Unknown config \"%v\"", Key)
 	}
@@ -182,6 +285,11 @@ func (Router *THttpRouter) ServeHTTP(Writer http.ResponseWriter, Request *http.R
 	}
 
 	SessionID := GetRequestSessionID(Request)
+	AccountID, CSRFToken := SessionLookup(SessionID, IPAddress)
+	if AccountID <= 0 {
+		CSRFToken = AnonymousCSRFToken(IPAddress)
+	}
+
 	Context := THttpRequestContext{
 		Request:   Request,
 		Writer:    Writer,
@@ -189,7 +297,9 @@ func (Router *THttpRouter) ServeHTTP(Writer http.ResponseWriter, Request *http.R
 		Params:    nil,
 		IPAddress: IPAddress,
 		SessionID: SessionID,
-		AccountID: SessionLookup(SessionID, IPAddress),
+		AccountID: AccountID,
+		CSRFToken: CSRFToken,
+		Backend:   Router.Backend,
 	}
 
 	for Index := len(Router.Routes) - 1; Index >= 0; Index -= 1 {
@@ -204,6 +314,9 @@ func (Router *THttpRouter) ServeHTTP(Writer http.ResponseWriter, Request *http.R
 			if Route.AllowParams || len(Params) == 0 {
 				Context.Prefix = Route.Prefix
 				Context.Params = Params
+				if Request.Method == http.MethodPost && !CheckPostRequest(&Context, Route) {
+					return
+				}
 				Route.Handler(&Context)
 				return
 			}
@@ -213,6 +326,41 @@ func (Router *THttpRouter) ServeHTTP(Writer http.ResponseWriter, Request *http.R
 	Router.NotFound(&Context)
 }
 
+// NOTE(fusion): Checks done for every POST request that matched a route, before
+// its handler is called. If the request is rejected, the error page is rendered
+// here and false is returned.
+func CheckPostRequest(Context *THttpRequestContext, Route *THttpRoute) bool {
+	IsApi := strings.HasPrefix(Route.Prefix, "/api")
+
+	// NOTE(fusion): The API is exempt because it only accepts access tokens in
+	// the `Authorization` header which, unlike cookies, browsers never send on
+	// their own.
+	if !IsApi && !CheckCSRFToken(Context, Context.Request.PostFormValue("csrf_token")) {
+		InvalidForm(Context)
+		return false
+	}
+
+	// NOTE(fusion): Write actions can't be served from cached data so there is
+	// no point in letting them through while the query manager is unreachable.
+	// Logging out and the API's catch-all only touch local state, so they're
+	// always let through.
+	switch Route.Prefix {
+	case "/account/logout", "/api":
+		return true
+	}
+
+	if Context.Backend.IsDegraded() {
+		if IsApi {
+			ApiError(Context, http.StatusServiceUnavailable, "maintenance")
+		} else {
+			Maintenance(Context)
+		}
+		return false
+	}
+
+	return true
+}
+
 func Redirect(Context *THttpRequestContext, Path string) {
 	Context.Writer.Header().Set("Location", Path)
 	Context.Writer.WriteHeader(http.StatusTemporaryRedirect)
@@ -241,6 +389,34 @@ func InternalError(Context *THttpRequestContext) {
 	RequestError(Context, http.StatusInternalServerError)
 }
 
+func Maintenance(Context *THttpRequestContext) {
+	Context.Writer.WriteHeader(http.StatusServiceUnavailable)
+	RenderMessage(Context, "Maintenance",
+		"The server is undergoing maintenance. Please try again in a few minutes.")
+}
+
+func InvalidForm(Context *THttpRequestContext) {
+	g_LogWarn.Printf("Rejected \"%v %v\" from \"%v\": missing or invalid CSRF token",
+		Context.Request.Method, Context.Request.URL.Path, Context.Request.RemoteAddr)
+	Context.Writer.WriteHeader(http.StatusForbidden)
+	RenderMessage(Context, "Form Expired",
+		"This form has expired or wasn't submitted from this website. Go back,"+
+			" reload the page, and try again.")
+}
+
+func SetRetryAfter(Context *THttpRequestContext, Wait time.Duration) time.Duration {
+	Seconds := int(math.Ceil(Wait.Seconds()))
+	Context.Writer.Header().Set("Retry-After", strconv.Itoa(Seconds))
+	return time.Duration(Seconds) * time.Second
+}
+
+func TooManyRequests(Context *THttpRequestContext, Wait time.Duration) {
+	Wait = SetRetryAfter(Context, Wait)
+	Context.Writer.WriteHeader(http.StatusTooManyRequests)
+	RenderMessage(Context, "Too Many Attempts",
+		fmt.Sprintf("Too many attempts. Please try again in %v.", Wait))
+}
+
 func ResourceError(Context *THttpRequestContext, Status int) {
 	// IMPORTANT(fusion): This is used for resource errors in which case we
 	// don't want to render any HTML to avoid pointless traffic. `http.Error`
@@ -358,11 +534,16 @@ func HandleAccount(Context *THttpRequestContext) {
 			return
 		}
 
-		Result := CheckAccountPassword(AccountID, Password, Context.IPAddress)
+		if !g_LoginIPLimiter.Allow(Context, Context.IPAddress) ||
+			!g_LoginAccountLimiter.Allow(Context, strconv.Itoa(AccountID)) {
+			return
+		}
+
+		Result := Context.Backend.CheckAccountPassword(AccountID, Password, Context.IPAddress)
 		switch Result {
 		case 0:
 			// NOTE(fusion): Invalidate account's cached data just in case.
-			InvalidateAccountCachedData(AccountID)
+			Context.Backend.InvalidateAccountCachedData(AccountID)
 			SessionStart(Context, AccountID)
 			RenderAccountSummary(Context)
 		case 1, 2:
@@ -375,6 +556,8 @@ func HandleAccount(Context *THttpRequestContext) {
 			RenderMessage(Context, "Login Error", "Your account is banished.")
 		case 6:
 			RenderMessage(Context, "Login Error", "Your IP address is banished.")
+		case 7:
+			RenderMessage(Context, "Login Error", AccountNotActivatedMessage(Context, AccountID))
 		default:
 			RenderMessage(Context, "Login Error", "Internal error.")
 		}
@@ -383,11 +566,396 @@ func HandleAccount(Context *THttpRequestContext) {
 	}
 }
 
+func AccountNotActivatedMessage(Context *THttpRequestContext, AccountID int) string {
+	// NOTE(fusion): The password was correct so we may send a fresh activation
+	// link, in case the previous one got lost or expired.
+	Message := "Your account is not activated yet."
+	if Result, Summary := Context.Backend.GetAccountSummary(AccountID); Result == 0 &&
+		SendAccountActivationMail(AccountID, Summary.Email) {
+		Message += " A new activation link has been sent to your email."
+	}
+	return Message
+}
+
+func ConfirmAccountPassword(Context *THttpRequestContext, Heading string, Password string) bool {
+	// NOTE(fusion): Sensitive actions require the account password to be typed
+	// in again, even if the user is already logged in. The error message, if
+	// any, is rendered here so handlers only need to bail out.
+	if Password == "" {
+		RenderMessage(Context, Heading, "Password is not correct.")
+		return false
+	}
+
+	if !g_LoginIPLimiter.Allow(Context, Context.IPAddress) ||
+		!g_LoginAccountLimiter.Allow(Context, strconv.Itoa(Context.AccountID)) {
+		return false
+	}
+
+	Result := Context.Backend.CheckAccountPassword(Context.AccountID, Password, Context.IPAddress)
+	switch Result {
+	case 0:
+		return true
+	case 1, 2:
+		RenderMessage(Context, Heading, "Password is not correct.")
+	case 3:
+		RenderMessage(Context, Heading, "Account disabled for five minutes.")
+	case 4:
+		RenderMessage(Context, Heading, "IP address blocked for 30 minutes.")
+	case 5:
+		RenderMessage(Context, Heading, "Your account is banished.")
+	case 6:
+		RenderMessage(Context, Heading, "Your IP address is banished.")
+	case 7:
+		RenderMessage(Context, Heading, AccountNotActivatedMessage(Context, Context.AccountID))
+	default:
+		RenderMessage(Context, Heading, "Internal error.")
+	}
+	return false
+}
+
+// NOTE(fusion): Access tokens are revoked along with sessions whenever someone
+// may be taking the account back (e.g. after a password change or recovery), or
+// they'd remain a way in for whoever had access before.
+func RevokeAccountAccessTokens(Context *THttpRequestContext, AccountID int) bool {
+	if Result := Context.Backend.RevokeAllAccessTokens(AccountID); Result != 0 {
+		g_LogErr.Printf("Failed to revoke access tokens of account %v (%v)", AccountID, Result)
+		return false
+	}
+	return true
+}
+
 func HandleAccountLogout(Context *THttpRequestContext) {
 	SessionEnd(Context)
 	Redirect(Context, "/account")
 }
 
+func HandleAccountPassword(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	switch Context.Request.Method {
+	case http.MethodGet:
+		RenderAccountPassword(Context)
+	case http.MethodPost:
+		Password := Context.Request.FormValue("password")
+		NewPassword := Context.Request.FormValue("new_password")
+		if Password == "" || NewPassword == "" {
+			RenderMessage(Context, "Change Password Error", "All inputs are REQUIRED.")
+			return
+		}
+
+		if NewPassword != Context.Request.FormValue("new_password_confirm") {
+			RenderMessage(Context, "Change Password Error", "Passwords don't match.")
+			return
+		}
+
+		// TODO(fusion): Proper password checking.
+		if len(NewPassword) < 8 {
+			RenderMessage(Context, "Change Password Error", "Password must contain at least 8 characters.")
+			return
+		}
+
+		if !ConfirmAccountPassword(Context, "Change Password Error", Password) {
+			return
+		}
+
+		Result := Context.Backend.ChangePassword(Context.AccountID, NewPassword)
+		switch Result {
+		case 0:
+			// NOTE(fusion): Log out any other sessions that may have been started
+			// with the old password.
+			SessionEndAccount(Context.AccountID, Context.SessionID)
+			if RevokeAccountAccessTokens(Context, Context.AccountID) {
+				RenderMessage(Context, "Password Changed",
+					"Your password has been changed. Any other sessions have been logged"+
+						" out and all access tokens have been revoked.")
+			} else {
+				RenderMessage(Context, "Password Changed",
+					"Your password has been changed and any other sessions have been logged"+
+						" out, but access tokens couldn't be revoked. Please revoke them from"+
+						" your account summary.")
+			}
+		case 1:
+			RenderMessage(Context, "Change Password Error",
+				"Weirdly enough, your account doesn't exist. What have you been up to?")
+		default:
+			RenderMessage(Context, "Change Password Error", "Internal error.")
+		}
+	default:
+		NotFound(Context)
+	}
+}
+
+func HandleAccountEmail(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	switch Context.Request.Method {
+	case http.MethodGet:
+		RenderAccountEmail(Context)
+	case http.MethodPost:
+		Email := strings.TrimSpace(Context.Request.FormValue("email"))
+		Password := Context.Request.FormValue("password")
+		if Email == "" || Password == "" {
+			RenderMessage(Context, "Change Email Error", "All inputs are REQUIRED.")
+			return
+		}
+
+		if Email != strings.TrimSpace(Context.Request.FormValue("email_confirm")) {
+			RenderMessage(Context, "Change Email Error", "Emails don't match.")
+			return
+		}
+
+		// TODO(fusion): Proper email checking.
+		if !strings.Contains(Email, "@") {
+			RenderMessage(Context, "Change Email Error", "Invalid email.")
+			return
+		}
+
+		if !ConfirmAccountPassword(Context, "Change Email Error", Password) {
+			return
+		}
+
+		SummaryResult, Summary := Context.Backend.GetAccountSummary(Context.AccountID)
+		if SummaryResult != 0 {
+			RenderMessage(Context, "Change Email Error", "Internal error.")
+			return
+		}
+
+		// NOTE(fusion): The email is only changed after the link is followed,
+		// which proves the new address is valid and owned by the user. The link
+		// is also bound to the current email so it can't be used once the email
+		// has changed, e.g. to revert a newer change.
+		Token := SignToken("email", time.Now().Add(24*time.Hour),
+			strconv.Itoa(Context.AccountID), Summary.Email, Email)
+		if Token == "" {
+			RenderMessage(Context, "Change Email Error", "Internal error.")
+			return
+		}
+
+		Link := fmt.Sprintf("%v/account/email/confirm?token=%v",
+			g_WebsiteURL, url.QueryEscape(Token))
+		Body, Ok := RenderMail("mail_account_email.tmpl",
+			AccountEmailMailTmplData{
+				AccountID: Context.AccountID,
+				Email:     Email,
+				Link:      Link,
+			})
+		if !Ok {
+			RenderMessage(Context, "Change Email Error", "Internal error.")
+			return
+		}
+
+		if Err := SendMail(Email, "Email Change", Body); Err != nil {
+			g_LogErr.Printf("Failed to send email change confirmation to \"%v\": %v", Email, Err)
+			RenderMessage(Context, "Change Email Error", "Internal error.")
+			return
+		}
+
+		RenderMessage(Context, "Change Email",
+			fmt.Sprintf("A confirmation link has been sent to %v. Your email will"+
+				" only be changed after you follow it. The link is valid for 24 hours.",
+				Email))
+	default:
+		NotFound(Context)
+	}
+}
+
+func HandleAccountEmailConfirm(Context *THttpRequestContext) {
+	Values, Ok := VerifyToken("email", Context.Request.URL.Query().Get("token"))
+	if !Ok || len(Values) != 3 {
+		RenderMessage(Context, "Change Email Error", "The confirmation link is invalid or has expired.")
+		return
+	}
+
+	AccountID, Err := strconv.Atoi(Values[0])
+	if Err != nil {
+		g_LogErr.Printf("Failed to parse account id: %v", Err)
+		RenderMessage(Context, "Change Email Error", "The confirmation link is invalid or has expired.")
+		return
+	}
+
+	// NOTE(fusion): This is a write behind a GET, so it isn't covered by the
+	// degraded check on form submissions.
+	if Context.Backend.IsDegraded() {
+		Maintenance(Context)
+		return
+	}
+
+	// NOTE(fusion): Make sure we're comparing against the current email and not
+	// whatever was cached before it last changed.
+	Context.Backend.InvalidateAccountCachedData(AccountID)
+	SummaryResult, Summary := Context.Backend.GetAccountSummary(AccountID)
+	switch SummaryResult {
+	case 0:
+		if Summary.Email != Values[1] {
+			RenderMessage(Context, "Change Email Error", "The confirmation link is invalid or has expired.")
+			return
+		}
+	case 1:
+		RenderMessage(Context, "Change Email Error",
+			"Weirdly enough, your account doesn't exist. What have you been up to?")
+		return
+	default:
+		RenderMessage(Context, "Change Email Error", "Internal error.")
+		return
+	}
+
+	Email := Values[2]
+	Result := Context.Backend.ChangeEmail(AccountID, Email)
+	switch Result {
+	case 0:
+		Context.Backend.InvalidateAccountCachedData(AccountID)
+		RenderMessage(Context, "Email Changed",
+			fmt.Sprintf("Your account's email has been changed to %v.", Email))
+	case 1:
+		RenderMessage(Context, "Change Email Error",
+			"Weirdly enough, your account doesn't exist. What have you been up to?")
+	case 2:
+		RenderMessage(Context, "Change Email Error", "An account with that email already exists.")
+	default:
+		RenderMessage(Context, "Change Email Error", "Internal error.")
+	}
+}
+
+func HandleAccountDelete(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	switch Context.Request.Method {
+	case http.MethodGet:
+		RenderAccountDelete(Context)
+	case http.MethodPost:
+		Password := Context.Request.FormValue("password")
+		if Password == "" {
+			RenderMessage(Context, "Delete Account Error", "All inputs are REQUIRED.")
+			return
+		}
+
+		if !ConfirmAccountPassword(Context, "Delete Account Error", Password) {
+			return
+		}
+
+		AccountID := Context.AccountID
+		Result := Context.Backend.DeleteAccount(AccountID)
+		switch Result {
+		case 0:
+			Context.Backend.InvalidateAccountCachedData(AccountID)
+			RevokeAccountAccessTokens(Context, AccountID)
+			SessionEnd(Context)
+			SessionEndAccount(AccountID, nil)
+			RenderMessage(Context, "Account Deleted",
+				"Your account has been scheduled for deletion. You may still login and"+
+					" cancel it from your account summary until the grace period ends.")
+		case 1:
+			RenderMessage(Context, "Delete Account Error",
+				"Weirdly enough, your account doesn't exist. What have you been up to?")
+		case 2:
+			RenderMessage(Context, "Delete Account Error", "All characters must be offline.")
+		default:
+			RenderMessage(Context, "Delete Account Error", "Internal error.")
+		}
+	default:
+		NotFound(Context)
+	}
+}
+
+func HandleAccountDeleteCancel(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	Result := Context.Backend.UndeleteAccount(Context.AccountID)
+	switch Result {
+	case 0:
+		Context.Backend.InvalidateAccountCachedData(Context.AccountID)
+		RenderMessage(Context, "Account Deletion Cancelled",
+			"Your account is no longer scheduled for deletion.")
+	case 1:
+		RenderMessage(Context, "Cancel Account Deletion Error",
+			"Weirdly enough, your account doesn't exist. What have you been up to?")
+	default:
+		RenderMessage(Context, "Cancel Account Deletion Error", "Internal error.")
+	}
+}
+
+func HandleAccountTokenCreate(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	Name := strings.TrimSpace(Context.Request.FormValue("name"))
+	if Name == "" || len(Name) > MaxAccessTokenNameLength {
+		RenderMessage(Context, "Create Access Token Error",
+			fmt.Sprintf("Name must contain between 1 and %v characters.", MaxAccessTokenNameLength))
+		return
+	}
+
+	Scopes := ParseAccessTokenScopes(Context.Request.FormValue("scope"))
+	if Scopes == 0 {
+		RenderMessage(Context, "Create Access Token Error", "Invalid scope.")
+		return
+	}
+
+	// NOTE(fusion): Tokens don't expire, so creating one must take more than a
+	// session cookie, which could have been stolen.
+	Password := Context.Request.FormValue("password")
+	if !ConfirmAccountPassword(Context, "Create Access Token Error", Password) {
+		return
+	}
+
+	Token := GenerateAccessToken()
+	if Token == "" {
+		RenderMessage(Context, "Create Access Token Error", "Internal error.")
+		return
+	}
+
+	Result := Context.Backend.CreateAccessToken(Context.AccountID, Name, HashAccessToken(Token), Scopes)
+	switch Result {
+	case 0:
+		RenderAccountTokenCreated(Context, Name, Token)
+	case 1:
+		RenderMessage(Context, "Create Access Token Error",
+			"Weirdly enough, your account doesn't exist. What have you been up to?")
+	case 2:
+		RenderMessage(Context, "Create Access Token Error",
+			"Your account has too many access tokens. Revoke one you no longer use and try again.")
+	default:
+		RenderMessage(Context, "Create Access Token Error", "Internal error.")
+	}
+}
+
+func HandleAccountTokenRevoke(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	TokenID, Err := strconv.Atoi(Context.Request.FormValue("id"))
+	if Err != nil {
+		BadRequest(Context)
+		return
+	}
+
+	Result := Context.Backend.RevokeAccessToken(Context.AccountID, TokenID)
+	switch Result {
+	case 0:
+		RenderAccountSummary(Context)
+	case 1:
+		RenderMessage(Context, "Revoke Access Token Error", "That access token doesn't exist.")
+	default:
+		RenderMessage(Context, "Revoke Access Token Error", "Internal error.")
+	}
+}
+
 func HandleAccountCreate(Context *THttpRequestContext) {
 	if Context.AccountID > 0 {
 		Redirect(Context, "/account")
@@ -435,11 +1003,26 @@ func HandleAccountCreate(Context *THttpRequestContext) {
 			return
 		}
 
-		Result := CreateAccount(AccountID, Email, Password)
+		if !g_AccountCreateIPLimiter.Allow(Context, Context.IPAddress) {
+			return
+		}
+
+		Result := Context.Backend.CreateAccount(AccountID, Email, Password, !g_RequireEmailActivation)
 		switch Result {
 		case 0:
-			RenderMessage(Context, "Account Created",
-				"Your account has been created. Head back to the login page to access it.")
+			if !g_RequireEmailActivation {
+				RenderMessage(Context, "Account Created",
+					"Your account has been created. Head back to the login page to access it.")
+			} else if SendAccountActivationMail(AccountID, Email) {
+				RenderMessage(Context, "Account Created",
+					fmt.Sprintf("Your account has been created. An activation link has"+
+						" been sent to %v and must be followed before you can login.",
+						Email))
+			} else {
+				RenderMessage(Context, "Account Created",
+					"Your account has been created but we failed to send the activation"+
+						" link. Try to login in a few moments to receive a new one.")
+			}
 		case 1:
 			RenderMessage(Context, "Create Account Error", "An account with that number already exists.")
 		case 2:
@@ -452,6 +1035,71 @@ func HandleAccountCreate(Context *THttpRequestContext) {
 	}
 }
 
+func SendAccountActivationMail(AccountID int, Email string) bool {
+	Token := SignToken("activate", time.Now().Add(72*time.Hour), strconv.Itoa(AccountID))
+	if Token == "" {
+		return false
+	}
+
+	Body, Ok := RenderMail("mail_account_activate.tmpl",
+		AccountActivateMailTmplData{
+			AccountID: AccountID,
+			Link:      fmt.Sprintf("%v/account/activate/%v", g_WebsiteURL, Token),
+		})
+	if !Ok {
+		return false
+	}
+
+	if Err := SendMail(Email, "Account Activation", Body); Err != nil {
+		g_LogErr.Printf("Failed to send activation email to \"%v\": %v", Email, Err)
+		return false
+	}
+
+	return true
+}
+
+func HandleAccountActivate(Context *THttpRequestContext) {
+	if len(Context.Params) != 1 {
+		NotFound(Context)
+		return
+	}
+
+	Values, Ok := VerifyToken("activate", Context.Params[0])
+	if !Ok || len(Values) != 1 {
+		RenderMessage(Context, "Activate Account Error",
+			"The activation link is invalid or has expired. Try to login to receive a new one.")
+		return
+	}
+
+	AccountID, Err := strconv.Atoi(Values[0])
+	if Err != nil {
+		g_LogErr.Printf("Failed to parse account id: %v", Err)
+		RenderMessage(Context, "Activate Account Error",
+			"The activation link is invalid or has expired. Try to login to receive a new one.")
+		return
+	}
+
+	// NOTE(fusion): Activation links are followed with a GET, which skips the
+	// degraded check done on form submissions.
+	if Context.Backend.IsDegraded() {
+		Maintenance(Context)
+		return
+	}
+
+	Result := Context.Backend.ActivateAccount(AccountID)
+	switch Result {
+	case 0:
+		Context.Backend.InvalidateAccountCachedData(AccountID)
+		RenderMessage(Context, "Account Activated",
+			"Your account has been activated. Head back to the login page to access it.")
+	case 1:
+		RenderMessage(Context, "Activate Account Error",
+			"Weirdly enough, your account doesn't exist. What have you been up to?")
+	default:
+		RenderMessage(Context, "Activate Account Error", "Internal error.")
+	}
+}
+
 func HandleAccountRecover(Context *THttpRequestContext) {
 	if Context.AccountID > 0 {
 		Redirect(Context, "/account")
@@ -461,6 +1109,103 @@ func HandleAccountRecover(Context *THttpRequestContext) {
 	switch Context.Request.Method {
 	case http.MethodGet:
 		RenderAccountRecover(Context)
+	case http.MethodPost:
+		Email := strings.TrimSpace(Context.Request.FormValue("email"))
+		if Email == "" {
+			RenderMessage(Context, "Recover Account Error", "Invalid email.")
+			return
+		}
+
+		if !g_AccountRecoverIPLimiter.Allow(Context, Context.IPAddress) {
+			return
+		}
+
+		Result, AccountID, Token := Context.Backend.RequestAccountRecovery(Email, Context.IPAddress)
+		switch Result {
+		case 0:
+			Link := fmt.Sprintf("%v/account/recover/confirm?token=%v",
+				g_WebsiteURL, url.QueryEscape(Token))
+			Body, Ok := RenderMail("mail_account_recover.tmpl",
+				AccountRecoverMailTmplData{
+					AccountID: AccountID,
+					Link:      Link,
+				})
+			if Ok {
+				go func() {
+					if Err := SendMail(Email, "Account Recovery", Body); Err != nil {
+						g_LogErr.Printf("Failed to send recovery email to \"%v\": %v", Email, Err)
+					}
+				}()
+			}
+			fallthrough
+		case 1:
+			// NOTE(fusion): Don't let anyone probe which emails are registered. The
+			// mail is sent in the background and failures are only logged so that
+			// registered emails don't stand out by their response or how long it
+			// takes.
+			RenderMessage(Context, "Recover Account",
+				"If there is an account registered with that email, a message with"+
+					" instructions on how to recover it has been sent.")
+		default:
+			RenderMessage(Context, "Recover Account Error", "Internal error.")
+		}
+	default:
+		NotFound(Context)
+	}
+}
+
+func HandleAccountRecoverConfirm(Context *THttpRequestContext) {
+	if Context.AccountID > 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	switch Context.Request.Method {
+	case http.MethodGet:
+		Token := Context.Request.URL.Query().Get("token")
+		if Token == "" {
+			Redirect(Context, "/account/recover")
+			return
+		}
+
+		RenderAccountRecoverConfirm(Context, Token)
+	case http.MethodPost:
+		Token := Context.Request.FormValue("token")
+		Password := Context.Request.FormValue("password")
+		if Token == "" || Password == "" {
+			RenderMessage(Context, "Recover Account Error", "All inputs are REQUIRED.")
+			return
+		}
+
+		if Password != Context.Request.FormValue("password_confirm") {
+			RenderMessage(Context, "Recover Account Error", "Passwords don't match.")
+			return
+		}
+
+		// TODO(fusion): Proper password checking.
+		if len(Password) < 8 {
+			RenderMessage(Context, "Recover Account Error", "Password must contain at least 8 characters.")
+			return
+		}
+
+		Result, AccountID := Context.Backend.RecoverAccount(Token, Password)
+		switch Result {
+		case 0:
+			SessionEndAccount(AccountID, nil)
+			if RevokeAccountAccessTokens(Context, AccountID) {
+				RenderMessage(Context, "Account Recovered",
+					"Your password has been changed and all access tokens have been revoked."+
+						" Head back to the login page to access your account.")
+			} else {
+				RenderMessage(Context, "Account Recovered",
+					"Your password has been changed but access tokens couldn't be revoked."+
+						" Head back to the login page and revoke them from your account summary.")
+			}
+		case 1:
+			RenderMessage(Context, "Recover Account Error", "The recovery link is invalid or has expired.")
+		default:
+			RenderMessage(Context, "Recover Account Error", "Internal error.")
+		}
 	default:
 		NotFound(Context)
 	}
@@ -477,7 +1222,7 @@ func HandleCharacterCreate(Context *THttpRequestContext) {
 		RenderCharacterCreate(Context)
 	case http.MethodPost:
 		World := strings.TrimSpace(Context.Request.FormValue("world"))
-		if World == "" || GetWorld(World) == nil {
+		if World == "" || Context.Backend.GetWorld(World) == nil {
 			RenderMessage(Context, "Create Character Error", "Invalid world.")
 			return
 		}
@@ -498,12 +1243,12 @@ func HandleCharacterCreate(Context *THttpRequestContext) {
 			return
 		}
 
-		Result := CreateCharacter(World, Context.AccountID, Name, Sex)
+		Result := Context.Backend.CreateCharacter(World, Context.AccountID, Name, Sex)
 		switch Result {
 		case 0:
 			// NOTE(fusion): Invalidate account's cached data so the new character
 			// is displayed in the account's summary.
-			InvalidateAccountCachedData(Context.AccountID)
+			Context.Backend.InvalidateAccountCachedData(Context.AccountID)
 			RenderMessage(Context, "Character Created",
 				fmt.Sprintf("And so, %v was brought into this world. May fortune"+
 					" favor your blade and guide your steps through the trials ahead.",
@@ -524,13 +1269,156 @@ func HandleCharacterCreate(Context *THttpRequestContext) {
 	}
 }
 
+func FindAccountCharacter(Account *TAccountSummary, CharacterName string) *TCharacterSummary {
+	for Index := range Account.Characters {
+		if strings.EqualFold(Account.Characters[Index].Name, CharacterName) {
+			return &Account.Characters[Index]
+		}
+	}
+	return nil
+}
+
+func HandleCharacterDeletion(Context *THttpRequestContext, Undelete bool) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	Heading := "Delete Character Error"
+	if Undelete {
+		Heading = "Undelete Character Error"
+	}
+
+	switch Context.Request.Method {
+	case http.MethodGet:
+		CharacterName := Context.Request.URL.Query().Get("name")
+		if CharacterName == "" {
+			Redirect(Context, "/account")
+			return
+		}
+
+		RenderCharacterDelete(Context, CharacterName, Undelete)
+	case http.MethodPost:
+		CharacterName := strings.TrimSpace(Context.Request.FormValue("name"))
+		Password := Context.Request.FormValue("password")
+		if CharacterName == "" || Password == "" {
+			RenderMessage(Context, Heading, "All inputs are REQUIRED.")
+			return
+		}
+
+		// NOTE(fusion): Make sure we're looking at up to date character data
+		// before checking whether it is online. The query manager should also
+		// check it but we want to give a proper message.
+		Context.Backend.InvalidateAccountCachedData(Context.AccountID)
+		Result, Account := Context.Backend.GetAccountSummary(Context.AccountID)
+		if Result != 0 {
+			RenderMessage(Context, Heading, "Internal error.")
+			return
+		}
+
+		Character := FindAccountCharacter(&Account, CharacterName)
+		if Character == nil {
+			RenderMessage(Context, Heading, "That character doesn't belong to your account.")
+			return
+		}
+
+		if !Undelete && Character.Deleted {
+			RenderMessage(Context, Heading, "That character is already scheduled for deletion.")
+			return
+		}
+
+		if Undelete && !Character.Deleted {
+			RenderMessage(Context, Heading, "That character is not scheduled for deletion.")
+			return
+		}
+
+		if Character.Online {
+			RenderMessage(Context, Heading, "That character must be offline.")
+			return
+		}
+
+		if !ConfirmAccountPassword(Context, Heading, Password) {
+			return
+		}
+
+		if Undelete {
+			Result = Context.Backend.UndeleteCharacter(Context.AccountID, Character.Name)
+		} else {
+			Result = Context.Backend.DeleteCharacter(Context.AccountID, Character.Name)
+		}
+
+		switch Result {
+		case 0:
+			Context.Backend.InvalidateAccountCachedData(Context.AccountID)
+			Context.Backend.InvalidateCharacterCachedData(Character.Name)
+			if Undelete {
+				RenderMessage(Context, "Character Undeleted",
+					fmt.Sprintf("%v is no longer scheduled for deletion.", Character.Name))
+			} else {
+				RenderMessage(Context, "Character Deleted",
+					fmt.Sprintf("%v has been scheduled for deletion. You may still undelete"+
+						" it from your account summary until it is removed for good.",
+						Character.Name))
+			}
+		case 1:
+			RenderMessage(Context, Heading, "That character doesn't belong to your account.")
+		case 2:
+			RenderMessage(Context, Heading, "That character must be offline.")
+		default:
+			RenderMessage(Context, Heading, "Internal error.")
+		}
+	default:
+		NotFound(Context)
+	}
+}
+
+func HandleCharacterDelete(Context *THttpRequestContext) {
+	HandleCharacterDeletion(Context, false)
+}
+
+func HandleCharacterUndelete(Context *THttpRequestContext) {
+	HandleCharacterDeletion(Context, true)
+}
+
+func HandleCharacterHide(Context *THttpRequestContext) {
+	if Context.AccountID <= 0 {
+		Redirect(Context, "/account")
+		return
+	}
+
+	CharacterName := strings.TrimSpace(Context.Request.FormValue("name"))
+	if CharacterName == "" {
+		BadRequest(Context)
+		return
+	}
+
+	Hidden := ParseBoolean(Context.Request.FormValue("hidden"))
+	Result := Context.Backend.SetCharacterHidden(Context.AccountID, CharacterName, Hidden)
+	switch Result {
+	case 0:
+		// NOTE(fusion): The character is listed on the profile of every other
+		// character in the account so we need to invalidate all of them.
+		if SummaryResult, Account := Context.Backend.GetAccountSummary(Context.AccountID); SummaryResult == 0 {
+			for _, Character := range Account.Characters {
+				Context.Backend.InvalidateCharacterCachedData(Character.Name)
+			}
+		}
+		Context.Backend.InvalidateAccountCachedData(Context.AccountID)
+		RenderAccountSummary(Context)
+	case 1:
+		RenderMessage(Context, "Character Visibility Error", "That character doesn't belong to your account.")
+	default:
+		RenderMessage(Context, "Character Visibility Error", "Internal error.")
+	}
+}
+
 func HandleCharacterProfile(Context *THttpRequestContext) {
 	QueryValues := Context.Request.URL.Query()
 	CharacterName := QueryValues.Get("name")
 	if CharacterName == "" {
 		RenderCharacterProfile(Context, nil)
 	} else {
-		Result, Character := GetCharacterProfile(CharacterName)
+		Result, Character := Context.Backend.GetCharacterProfile(CharacterName)
 		switch Result {
 		case 0:
 			RenderCharacterProfile(Context, &Character)
@@ -545,54 +1433,199 @@ func HandleCharacterProfile(Context *THttpRequestContext) {
 func HandleKillStatistics(Context *THttpRequestContext) {
 	QueryValues := Context.Request.URL.Query()
 	WorldName := QueryValues.Get("world")
-	if WorldName == "" || GetWorld(WorldName) == nil {
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
 		Redirect(Context, "/world")
 	} else {
 		RenderKillStatistics(Context, WorldName)
 	}
 }
 
-func HandleWorld(Context *THttpRequestContext) {
+func HandleLatestDeaths(Context *THttpRequestContext) {
 	QueryValues := Context.Request.URL.Query()
-	WorldName := QueryValues.Get("name")
-	if WorldName == "" || GetWorld(WorldName) == nil {
-		RenderWorldList(Context)
+	WorldName := QueryValues.Get("world")
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
+		Redirect(Context, "/world")
 	} else {
-		RenderWorldInfo(Context, WorldName)
+		RenderLatestDeaths(Context, WorldName)
 	}
 }
 
-func main() {
-	g_Log.Print("Tibia Web Server v0.2")
-	if !ReadConfig("config.cfg", WebKVCallback) {
+func HandleGuilds(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	WorldName := QueryValues.Get("world")
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
+		Redirect(Context, "/world")
+	} else {
+		RenderGuildList(Context, WorldName)
+	}
+}
+
+func HandleGuild(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	GuildName := QueryValues.Get("name")
+	if GuildName == "" {
+		Redirect(Context, "/world")
 		return
 	}
 
-	defer ExitQuery()
-	defer ExitMail()
-	defer ExitTemplates()
-	if !InitQuery() || !InitMail() || !InitTemplates() {
+	Result, Guild := Context.Backend.GetGuild(GuildName)
+	switch Result {
+	case 0:
+		RenderGuildInfo(Context, &Guild)
+	case 1:
+		RenderMessage(Context, "Search Error", "A guild with that name doesn't exist.")
+	default:
+		RenderMessage(Context, "Search Error", "Internal error.")
+	}
+}
+
+func HandleHouses(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	WorldName := QueryValues.Get("world")
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
+		Redirect(Context, "/world")
+	} else {
+		RenderHouseList(Context, WorldName, QueryValues.Get("town"))
+	}
+}
+
+func HandleHouse(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	WorldName := QueryValues.Get("world")
+	HouseID, Err := strconv.Atoi(QueryValues.Get("id"))
+	if Err != nil {
+		BadRequest(Context)
 		return
 	}
 
-	Router := THttpRouter{}
+	var House *THouse
+	if WorldName != "" && Context.Backend.GetWorld(WorldName) != nil {
+		House = Context.Backend.GetHouse(WorldName, HouseID)
+	}
+
+	if House == nil {
+		Context.Writer.WriteHeader(http.StatusNotFound)
+		RenderMessage(Context, "Search Error",
+			"A house with that id doesn't exist in that world.")
+		return
+	}
+
+	RenderHouseInfo(Context, WorldName, House)
+}
+
+func HandleHighscores(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	WorldName := QueryValues.Get("world")
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
+		Redirect(Context, "/world")
+		return
+	}
+
+	Category := HIGHSCORE_LEVEL
+	if CategoryKey := QueryValues.Get("category"); CategoryKey != "" {
+		Category = ParseHighscoreCategory(CategoryKey)
+		if Category == -1 {
+			BadRequest(Context)
+			return
+		}
+	}
+
+	Page := 1
+	if PageString := QueryValues.Get("page"); PageString != "" {
+		var Err error
+		Page, Err = strconv.Atoi(PageString)
+		if Err != nil || Page < 1 {
+			BadRequest(Context)
+			return
+		}
+	}
+
+	RenderHighscores(Context, WorldName, Category, Page)
+}
+
+func HandleWorld(Context *THttpRequestContext) {
+	QueryValues := Context.Request.URL.Query()
+	WorldName := QueryValues.Get("name")
+	if WorldName == "" || Context.Backend.GetWorld(WorldName) == nil {
+		RenderWorldList(Context)
+	} else {
+		RenderWorldInfo(Context, WorldName)
+	}
+}
+
+// NOTE(fusion): Routes are set up here, instead of `main`, so tests can serve
+// the exact same website from any backend.
+func NewHttpRouter(Backend TQueryBackend) *THttpRouter {
+	Router := &THttpRouter{Backend: Backend}
 	Router.Add("GET", "/res/", HandleResource)
 	Router.Add("GET", "/favicon.ico", HandleFavicon)
 	Router.Add("GET", "/", HandleIndex)
 	Router.Add("GET", "/index", HandleIndex)
 	Router.Add("GET", "/account", HandleAccount)
 	Router.Add("POST", "/account", HandleAccount)
-	Router.Add("GET", "/account/logout", HandleAccountLogout)
+	Router.Add("POST", "/account/logout", HandleAccountLogout)
+	Router.Add("GET", "/account/activate/", HandleAccountActivate)
+	Router.Add("GET", "/account/delete", HandleAccountDelete)
+	Router.Add("POST", "/account/delete", HandleAccountDelete)
+	Router.Add("POST", "/account/delete/cancel", HandleAccountDeleteCancel)
+	Router.Add("GET", "/account/email", HandleAccountEmail)
+	Router.Add("POST", "/account/email", HandleAccountEmail)
+	Router.Add("GET", "/account/email/confirm", HandleAccountEmailConfirm)
+	Router.Add("GET", "/account/password", HandleAccountPassword)
+	Router.Add("POST", "/account/password", HandleAccountPassword)
+	Router.Add("POST", "/account/token/create", HandleAccountTokenCreate)
+	Router.Add("POST", "/account/token/revoke", HandleAccountTokenRevoke)
 	Router.Add("GET", "/account/create", HandleAccountCreate)
 	Router.Add("POST", "/account/create", HandleAccountCreate)
 	Router.Add("GET", "/account/recover", HandleAccountRecover)
 	Router.Add("POST", "/account/recover", HandleAccountRecover)
+	Router.Add("GET", "/account/recover/confirm", HandleAccountRecoverConfirm)
+	Router.Add("POST", "/account/recover/confirm", HandleAccountRecoverConfirm)
 	Router.Add("GET", "/character/create", HandleCharacterCreate)
 	Router.Add("POST", "/character/create", HandleCharacterCreate)
+	Router.Add("GET", "/character/delete", HandleCharacterDelete)
+	Router.Add("POST", "/character/delete", HandleCharacterDelete)
+	Router.Add("POST", "/character/hide", HandleCharacterHide)
+	Router.Add("GET", "/character/undelete", HandleCharacterUndelete)
+	Router.Add("POST", "/character/undelete", HandleCharacterUndelete)
 	Router.Add("GET", "/character", HandleCharacterProfile)
+	Router.Add("GET", "/deaths", HandleLatestDeaths)
+	Router.Add("GET", "/guilds", HandleGuilds)
+	Router.Add("GET", "/guild", HandleGuild)
+	Router.Add("GET", "/highscores", HandleHighscores)
+	Router.Add("GET", "/houses", HandleHouses)
+	// NOTE(fusion): House ids are only unique within a world, since each game
+	// server has its own houses, so `/house` needs both `world` and `id`.
+	Router.Add("GET", "/house", HandleHouse)
 	Router.Add("GET", "/killstatistics", HandleKillStatistics)
 	Router.Add("GET", "/world", HandleWorld)
+	Router.Add("GET", "/api/v1/worlds/", HandleApiWorlds)
+	Router.Add("GET", "/api/v1/characters/", HandleApiCharacters)
+	Router.Add("GET", "/api/v1/account", HandleApiAccount)
+	Router.Add("POST", "/api/v1/account/characters", HandleApiAccountCharacterCreate)
+	Router.Add("OPTIONS", "/api/", HandleApiPreflight)
+	Router.Add("", "/api/", ApiNotFound)
 	Router.NotFound = NotFound
+	return Router
+}
+
+func main() {
+	g_Log.Print("Tibia Web Server v0.2")
+	if !ReadConfig("config.cfg", WebKVCallback) {
+		return
+	}
+
+	defer ExitQuery()
+	defer ExitTokens()
+	defer ExitMail()
+	defer ExitTemplates()
+	defer ExitRateLimits()
+	Backend := InitQueryBackend()
+	if Backend == nil || !InitTokens() || !InitMail() || !InitTemplates() || !InitRateLimits() {
+		return
+	}
+
+	Router := NewHttpRouter(Backend)
 
 	// NOTE(fusion): Force the server to run on IPv4 because that is the only
 	// format the query manager currently handles. Trying to use IPv6 will cause
@@ -605,7 +1638,7 @@ func main() {
 		}
 
 		g_Log.Printf("Running over HTTPS on port %v", g_HttpsPort)
-		g_Log.Print(http.ServeTLS(Listener, &Router, g_HttpsCertFile, g_HttpsKeyFile))
+		g_Log.Print(http.ServeTLS(Listener, Router, g_HttpsCertFile, g_HttpsKeyFile))
 	} else {
 		g_LogWarn.Print("The server is setup to run over HTTP which is NOT SECURE" +
 			" and prone to a man-in-the-middle or eavesdropping attack. This setup" +
@@ -618,6 +1651,6 @@ func main() {
 		}
 
 		g_Log.Printf("Running over HTTP on port %v", g_HttpPort)
-		g_Log.Print(http.Serve(Listener, &Router))
+		g_Log.Print(http.Serve(Listener, Router))
 	}
 }
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NOTE(fusion): Templates are embedded so tests don't depend on the working
+// directory, and every test gets its own in-memory backend so they can't step
+// on each other's data. Sessions are still global, but each client only ever
+// sees its own.
+
+//go:embed templates/*.tmpl
+var g_TestTemplateFiles embed.FS
+
+type (
+	TTestClient struct {
+		T         *testing.T
+		Router    http.Handler
+		IPAddress string
+		SessionID string
+		CSRFToken string
+	}
+
+	TDegradedBackend struct {
+		*TMemoryBackend
+	}
+)
+
+var (
+	g_CSRFTokenPattern   = regexp.MustCompile(`name="csrf_token" value="([^"]*)"`)
+	g_AccessTokenPattern = regexp.MustCompile(`value="(` + AccessTokenPrefix + `[^"]*)"`)
+)
+
+func (Backend TDegradedBackend) IsDegraded() bool {
+	return true
+}
+
+func TestMain(M *testing.M) {
+	TemplateFiles, Err := fs.Sub(g_TestTemplateFiles, "templates")
+	if Err != nil || !LoadTemplates(TemplateFiles) || !InitTokens() {
+		os.Exit(1)
+	}
+	os.Exit(M.Run())
+}
+
+func NewTestClient(T *testing.T, Backend TQueryBackend) *TTestClient {
+	return &TTestClient{
+		T:         T,
+		Router:    NewHttpRouter(Backend),
+		IPAddress: "192.0.2.1",
+	}
+}
+
+func (Client *TTestClient) Do(Request *http.Request) *httptest.ResponseRecorder {
+	Request.RemoteAddr = Client.IPAddress + ":1234"
+	if Client.SessionID != "" {
+		Request.AddCookie(&http.Cookie{Name: "GOSESSID", Value: Client.SessionID})
+	}
+
+	Recorder := httptest.NewRecorder()
+	Client.Router.ServeHTTP(Recorder, Request)
+	for _, Cookie := range Recorder.Result().Cookies() {
+		if Cookie.Name == "GOSESSID" {
+			Client.SessionID = Cookie.Value
+		}
+	}
+
+	// NOTE(fusion): Keep the CSRF token of the last page, the same way a user
+	// would submit a form from the page they're looking at.
+	if Match := g_CSRFTokenPattern.FindStringSubmatch(Recorder.Body.String()); Match != nil {
+		Client.CSRFToken = Match[1]
+	}
+	return Recorder
+}
+
+func (Client *TTestClient) Get(Path string) *httptest.ResponseRecorder {
+	return Client.Do(httptest.NewRequest(http.MethodGet, Path, nil))
+}
+
+func (Client *TTestClient) Post(Path string, Values url.Values) *httptest.ResponseRecorder {
+	if Values == nil {
+		Values = url.Values{}
+	}
+
+	if !Values.Has("csrf_token") {
+		Values.Set("csrf_token", Client.CSRFToken)
+	}
+
+	Request := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(Values.Encode()))
+	Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return Client.Do(Request)
+}
+
+func (Client *TTestClient) Api(Method string, Path string, Token string, Body string) *httptest.ResponseRecorder {
+	Request := httptest.NewRequest(Method, Path, strings.NewReader(Body))
+	if Token != "" {
+		Request.Header.Set("Authorization", "Bearer "+Token)
+	}
+	return Client.Do(Request)
+}
+
+func (Client *TTestClient) Login(AccountID int, Password string) {
+	Client.T.Helper()
+	Client.Get("/account")
+	Response := Client.Post("/account", url.Values{
+		"account":  {strconv.Itoa(AccountID)},
+		"password": {Password},
+	})
+	if Response.Code != http.StatusOK || !strings.Contains(Response.Body.String(), "Logout") {
+		Client.T.Fatalf("login as %v failed (%v): %v", AccountID, Response.Code, Response.Body.String())
+	}
+}
+
+func Expect(T *testing.T, Response *httptest.ResponseRecorder, Status int, Contains ...string) {
+	T.Helper()
+	if Response.Code != Status {
+		T.Fatalf("expected status %v, got %v: %v", Status, Response.Code, Response.Body.String())
+	}
+
+	Body := Response.Body.String()
+	for _, String := range Contains {
+		if !strings.Contains(Body, String) {
+			T.Fatalf("expected body to contain %q: %v", String, Body)
+		}
+	}
+}
+
+func ExpectNot(T *testing.T, Response *httptest.ResponseRecorder, NotContains ...string) {
+	T.Helper()
+	Body := Response.Body.String()
+	for _, String := range NotContains {
+		if strings.Contains(Body, String) {
+			T.Fatalf("expected body to NOT contain %q: %v", String, Body)
+		}
+	}
+}
+
+func TestPublicPages(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Pages := []struct {
+		Path     string
+		Status   int
+		Contains string
+	}{
+		{"/", http.StatusTemporaryRedirect, ""},
+		{"/account", http.StatusOK, "Login"},
+		{"/account/create", http.StatusOK, "Create Account"},
+		{"/account/recover", http.StatusOK, "Recover Account"},
+		{"/world", http.StatusOK, "Antica"},
+		{"/world?name=Zanera", http.StatusOK, "Sample Knight"},
+		{"/character?name=Sample+Knight", http.StatusOK, "Elite Knight"},
+		{"/character?name=Nobody+Here", http.StatusOK, "with that name"},
+		{"/killstatistics?world=Zanera", http.StatusOK, "Dragon"},
+		{"/deaths?world=Zanera", http.StatusOK, "Sample Knight"},
+		{"/guilds?world=Zanera", http.StatusOK, "Sample Guild"},
+		{"/guild?name=Sample+Guild", http.StatusOK, "Sample Knight"},
+		{"/highscores?world=Zanera", http.StatusOK, "Sample Knight"},
+		{"/highscores?world=Zanera&category=sword", http.StatusOK, "Sample Knight"},
+		{"/houses?world=Zanera", http.StatusOK, "Thais Lighthouse"},
+		{"/house?world=Zanera&id=1", http.StatusOK, "Thais Lighthouse"},
+		{"/house?id=1", http.StatusNotFound, "in that world"},
+		{"/house?world=Antica&id=1", http.StatusNotFound, "in that world"},
+		{"/api/v1/worlds", http.StatusOK, `"name":"Zanera"`},
+		{"/api/v1/worlds/Zanera", http.StatusOK, `"online_characters"`},
+		{"/api/v1/worlds/Zanera/killstatistics", http.StatusOK, `"race_name":"Dragon"`},
+		{"/api/v1/characters/Sample%20Knight", http.StatusOK, `"name":"Sample Knight"`},
+		{"/api/v1/characters/Nobody", http.StatusNotFound, "character not found"},
+		{"/api/v1/nothing", http.StatusNotFound, "not found"},
+	}
+
+	for _, Page := range Pages {
+		T.Run(Page.Path, func(T *testing.T) {
+			Expect(T, Client.Get(Page.Path), Page.Status, Page.Contains)
+		})
+	}
+}
+
+func TestAccountPages(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Client.Login(111111, "tibia")
+	Pages := []struct {
+		Path     string
+		Contains string
+	}{
+		{"/account", "Sample Sorcerer"},
+		{"/account/password", "Change Password"},
+		{"/account/email", "Change Email"},
+		{"/account/delete", "Delete Account"},
+		{"/character/create", "Create Character"},
+		{"/character/delete?name=Sample+Druid", "Sample Druid"},
+	}
+
+	for _, Page := range Pages {
+		T.Run(Page.Path, func(T *testing.T) {
+			Expect(T, Client.Get(Page.Path), http.StatusOK, Page.Contains)
+		})
+	}
+}
+
+func TestLoginLogout(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Client.Get("/account")
+	Expect(T, Client.Post("/account", url.Values{"account": {"111111"}, "password": {"wrong"}}),
+		http.StatusOK, "Account or password is not correct.")
+
+	Client.Login(111111, "tibia")
+	Expect(T, Client.Get("/account"), http.StatusOK, "Sample Knight")
+
+	// NOTE(fusion): Logout is a POST so other websites can't log users out.
+	Expect(T, Client.Get("/account/logout"), http.StatusNotFound)
+	Expect(T, Client.Get("/account"), http.StatusOK, "Sample Knight")
+	Expect(T, Client.Post("/account/logout", nil), http.StatusTemporaryRedirect)
+	Expect(T, Client.Get("/account"), http.StatusOK, "Login")
+}
+
+func TestCSRF(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Values := url.Values{"account": {"111111"}, "password": {"tibia"}}
+	Values.Set("csrf_token", "")
+	Expect(T, Client.Post("/account", Values), http.StatusForbidden, "Form Expired")
+
+	// NOTE(fusion): Anonymous tokens are bound to the IP address.
+	Client.Get("/account")
+	Other := NewTestClient(T, Client.Router.(*THttpRouter).Backend)
+	Other.IPAddress = "192.0.2.2"
+	Values.Set("csrf_token", Client.CSRFToken)
+	Expect(T, Other.Post("/account", Values), http.StatusForbidden, "Form Expired")
+
+	// NOTE(fusion): Anonymous tokens are not accepted once logged in.
+	AnonymousToken := Client.CSRFToken
+	Client.Login(111111, "tibia")
+	Values = url.Values{"name": {"Sample Druid"}, "hidden": {"false"}}
+	Values.Set("csrf_token", AnonymousToken)
+	Expect(T, Client.Post("/character/hide", Values), http.StatusForbidden, "Form Expired")
+
+	Client.Get("/account")
+	Values.Set("csrf_token", Client.CSRFToken)
+	Expect(T, Client.Post("/character/hide", Values), http.StatusOK)
+
+	// NOTE(fusion): Unknown paths are not found, not forbidden.
+	Expect(T, Client.Post("/nothing/here", nil), http.StatusNotFound)
+}
+
+func TestCreateAccountAndCharacter(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Client.Get("/account/create")
+	Expect(T, Client.Post("/account/create", url.Values{
+		"account":          {"222222"},
+		"email":            {"new@localhost"},
+		"email_confirm":    {"new@localhost"},
+		"password":         {"password123"},
+		"password_confirm": {"password123"},
+	}), http.StatusOK, "Account Created")
+
+	Client.Login(222222, "password123")
+	Client.Get("/character/create")
+	Expect(T, Client.Post("/character/create", url.Values{
+		"name":  {"Brand New"},
+		"sex":   {"1"},
+		"world": {"Zanera"},
+	}), http.StatusOK, "Character Created")
+	Expect(T, Client.Get("/account"), http.StatusOK, "Brand New")
+
+	Expect(T, Client.Post("/character/create", url.Values{
+		"name":  {"Sample Knight"},
+		"sex":   {"1"},
+		"world": {"Zanera"},
+	}), http.StatusOK, "already exists")
+}
+
+func TestPasswordChange(T *testing.T) {
+	Backend := NewMemoryBackend()
+	Client := NewTestClient(T, Backend)
+	Client.Login(111111, "tibia")
+	Other := NewTestClient(T, Backend)
+	Other.Login(111111, "tibia")
+
+	Client.Get("/account")
+	Response := Client.Post("/account/token/create", url.Values{
+		"name":     {"Script"},
+		"scope":    {"read"},
+		"password": {"tibia"},
+	})
+	Expect(T, Response, http.StatusOK, "Access Token Created")
+	Token := g_AccessTokenPattern.FindStringSubmatch(Response.Body.String())[1]
+
+	Client.Get("/account/password")
+	Expect(T, Client.Post("/account/password", url.Values{
+		"password":             {"tibia"},
+		"new_password":         {"password123"},
+		"new_password_confirm": {"password123"},
+	}), http.StatusOK, "Password Changed", "access tokens have been revoked")
+
+	// NOTE(fusion): The session that changed the password is kept, while every
+	// other session and access token is gone.
+	Expect(T, Client.Get("/account"), http.StatusOK, "Sample Knight")
+	Expect(T, Other.Get("/account"), http.StatusOK, "Login")
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", Token, ""), http.StatusUnauthorized)
+
+	Other.Login(111111, "password123")
+}
+
+func TestAccessTokens(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+	Client.Login(111111, "tibia")
+	Client.Get("/account")
+	Expect(T, Client.Post("/account/token/create", url.Values{
+		"name":     {"Script"},
+		"scope":    {"characters"},
+		"password": {"wrong"},
+	}), http.StatusOK, "Password is not correct.")
+
+	Client.Get("/account")
+	Response := Client.Post("/account/token/create", url.Values{
+		"name":     {"Script"},
+		"scope":    {"characters"},
+		"password": {"tibia"},
+	})
+	Expect(T, Response, http.StatusOK, "Access Token Created")
+	Token := g_AccessTokenPattern.FindStringSubmatch(Response.Body.String())[1]
+
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", "", ""),
+		http.StatusUnauthorized, "missing access token")
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", "garbage", ""),
+		http.StatusUnauthorized, "invalid access token")
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", AccessTokenPrefix+"garbage", ""),
+		http.StatusUnauthorized, "invalid access token")
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", Token, ""),
+		http.StatusOK, `"account_id":111111`)
+	Expect(T, Client.Api(http.MethodPost, "/api/v1/account/characters", Token,
+		`{"world": "Antica", "name": "Api Character", "sex": 2}`),
+		http.StatusCreated, `"name":"Api Character"`)
+	Expect(T, Client.Api(http.MethodPost, "/api/v1/account/characters", Token,
+		`{"world": "Antica", "name": "Api Character", "sex": 2}`),
+		http.StatusConflict)
+
+	Response = Client.Get("/account")
+	Expect(T, Response, http.StatusOK, "Api Character", "Script")
+	Match := regexp.MustCompile(`name="id" value="(\d+)"`).FindStringSubmatch(Response.Body.String())
+	if Match == nil {
+		T.Fatalf("token id not found: %v", Response.Body.String())
+	}
+
+	Expect(T, Client.Post("/account/token/revoke", url.Values{"id": {Match[1]}}), http.StatusOK)
+	Expect(T, Client.Api(http.MethodGet, "/api/v1/account", Token, ""),
+		http.StatusUnauthorized, "invalid access token")
+}
+
+func TestCharacterHide(T *testing.T) {
+	Client := NewTestClient(T, NewMemoryBackend())
+
+	// NOTE(fusion): Characters are hidden from other profiles unless the owner
+	// opts in.
+	ExpectNot(T, Client.Get("/character?name=Sample+Knight"), "Sample Druid")
+	Response := Client.Get("/api/v1/characters/Sample%20Knight")
+	var Character TApiCharacter
+	if Err := json.Unmarshal(Response.Body.Bytes(), &Character); Err != nil {
+		T.Fatal(Err)
+	}
+	for _, Other := range Character.OtherCharacters {
+		if Other.Name == "Sample Druid" {
+			T.Fatalf("hidden character listed: %v", Response.Body.String())
+		}
+	}
+
+	Client.Login(111111, "tibia")
+	Expect(T, Client.Get("/account"), http.StatusOK, "Show on profile")
+	Expect(T, Client.Post("/character/hide", url.Values{
+		"name":   {"Sample Druid"},
+		"hidden": {"false"},
+	}), http.StatusOK, "Hide from profile")
+	Expect(T, Client.Get("/character?name=Sample+Knight"), http.StatusOK, "Sample Druid")
+}
+
+func TestEmailConfirm(T *testing.T) {
+	Backend := NewMemoryBackend()
+	Client := NewTestClient(T, Backend)
+	Link := func(CurrentEmail string, NewEmail string) string {
+		Token := SignToken("email", time.Now().Add(time.Hour), "111111", CurrentEmail, NewEmail)
+		return "/account/email/confirm?token=" + url.QueryEscape(Token)
+	}
+
+	First := Link("sample@localhost", "first@localhost")
+	Second := Link("sample@localhost", "second@localhost")
+	Expect(T, Client.Get(First), http.StatusOK, "first@localhost")
+	Expect(T, Client.Get(First), http.StatusOK, "invalid or has expired")
+	Expect(T, Client.Get(Second), http.StatusOK, "invalid or has expired")
+
+	if _, Summary := Backend.GetAccountSummary(111111); Summary.Email != "first@localhost" {
+		T.Fatalf("unexpected email %v", Summary.Email)
+	}
+}
+
+func TestDegraded(T *testing.T) {
+	Backend := NewMemoryBackend()
+	Client := NewTestClient(T, Backend)
+	Client.Login(111111, "tibia")
+	ExpectNot(T, Client.Get("/account"), "can't be reached")
+
+	// NOTE(fusion): Only writes are refused while the query manager is down, but
+	// logging out never needed it in the first place.
+	Client.Router = NewHttpRouter(TDegradedBackend{Backend})
+	Expect(T, Client.Get("/character?name=Sample+Knight"), http.StatusOK, "Elite Knight", "can't be reached")
+	Expect(T, Client.Post("/character/hide", url.Values{
+		"name":   {"Sample Druid"},
+		"hidden": {"false"},
+	}), http.StatusServiceUnavailable)
+	Expect(T, Client.Post("/nothing/here", nil), http.StatusNotFound)
+
+	Token := SignToken("email", time.Now().Add(time.Hour), "111111", "sample@localhost", "new@localhost")
+	Expect(T, Client.Get("/account/email/confirm?token="+url.QueryEscape(Token)),
+		http.StatusServiceUnavailable, "Maintenance")
+
+	Token = SignToken("activate", time.Now().Add(time.Hour), "111111")
+	Expect(T, Client.Get("/account/activate/"+Token), http.StatusServiceUnavailable, "Maintenance")
+
+	Expect(T, Client.Post("/account/logout", nil), http.StatusTemporaryRedirect)
+	Expect(T, Client.Get("/account"), http.StatusOK, "Login")
+}
+
+func TestRateLimit(T *testing.T) {
+	g_LoginBurstPerIP = 10
+	g_LoginBurstPerAccount = 2
+	if !InitRateLimits() {
+		T.Fatal("failed to init rate limits")
+	}
+	defer func() {
+		ExitRateLimits()
+		g_LoginIPLimiter = nil
+		g_LoginAccountLimiter = nil
+		g_AccountCreateIPLimiter = nil
+		g_AccountRecoverIPLimiter = nil
+		g_ApiTokenIPLimiter = nil
+	}()
+
+	Client := NewTestClient(T, NewMemoryBackend())
+	Client.Get("/account")
+	Values := url.Values{"account": {"111111"}, "password": {"wrong"}}
+	Expect(T, Client.Post("/account", Values), http.StatusOK, "not correct")
+	Expect(T, Client.Post("/account", Values), http.StatusOK, "not correct")
+
+	Response := Client.Post("/account", Values)
+	Expect(T, Response, http.StatusTooManyRequests, "Too Many Attempts")
+	if Response.Header().Get("Retry-After") == "" {
+		T.Fatal("missing Retry-After header")
+	}
+
+	// NOTE(fusion): Other accounts are still limited per IP address only.
+	Values.Set("account", "222222")
+	Expect(T, Client.Post("/account", Values), http.StatusOK, "not correct")
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"os"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,111 +27,245 @@ const (
 )
 
 const (
-	// TODO(fusion): There are newly created queries to support basic account
-	// management. A production ready website would need even more queries to
-	// allow account activation, recovery, deletion, password change, character
-	// deletion, etc...
-
-	QUERY_LOGIN                  = 0
-	QUERY_CHECK_ACCOUNT_PASSWORD = 10
-	QUERY_CREATE_ACCOUNT         = 100
-	QUERY_CREATE_CHARACTER       = 101
-	QUERY_GET_ACCOUNT_SUMMARY    = 102
-	QUERY_GET_CHARACTER_PROFILE  = 103
-	QUERY_GET_WORLDS             = 150
-	QUERY_GET_ONLINE_CHARACTERS  = 151
-	QUERY_GET_KILL_STATISTICS    = 152
+	// NOTE(fusion): Queries in the 100-149 range are used for account management
+	// while queries in the 150-199 range are used for public data. They're both
+	// specific to the website.
+
+	QUERY_LOGIN                    = 0
+	QUERY_CHECK_ACCOUNT_PASSWORD   = 10
+	QUERY_CREATE_ACCOUNT           = 100
+	QUERY_CREATE_CHARACTER         = 101
+	QUERY_GET_ACCOUNT_SUMMARY      = 102
+	QUERY_GET_CHARACTER_PROFILE    = 103
+	QUERY_REQUEST_ACCOUNT_RECOVERY = 104
+	QUERY_RECOVER_ACCOUNT          = 105
+	QUERY_CHANGE_PASSWORD          = 106
+	QUERY_CHANGE_EMAIL             = 107
+	QUERY_ACTIVATE_ACCOUNT         = 108
+	QUERY_DELETE_CHARACTER         = 109
+	QUERY_UNDELETE_CHARACTER       = 110
+	QUERY_DELETE_ACCOUNT           = 111
+	QUERY_UNDELETE_ACCOUNT         = 112
+	QUERY_SET_CHARACTER_HIDDEN     = 113
+	QUERY_CREATE_ACCESS_TOKEN      = 114
+	QUERY_GET_ACCESS_TOKENS        = 115
+	QUERY_REVOKE_ACCESS_TOKEN      = 116
+	QUERY_CHECK_ACCESS_TOKEN       = 117
+	QUERY_REVOKE_ALL_ACCESS_TOKENS = 118
+	QUERY_GET_WORLDS               = 150
+	QUERY_GET_ONLINE_CHARACTERS    = 151
+	QUERY_GET_KILL_STATISTICS      = 152
+	QUERY_GET_HIGHSCORES           = 153
+	QUERY_GET_GUILDS               = 154
+	QUERY_GET_GUILD                = 155
+	QUERY_GET_HOUSES               = 156
+	QUERY_GET_CHARACTER_DEATHS     = 157
+	QUERY_GET_LATEST_DEATHS        = 158
+)
+
+const (
+	HIGHSCORE_LEVEL     = 0
+	HIGHSCORE_MAGIC     = 1
+	HIGHSCORE_FIST      = 2
+	HIGHSCORE_CLUB      = 3
+	HIGHSCORE_SWORD     = 4
+	HIGHSCORE_AXE       = 5
+	HIGHSCORE_DISTANCE  = 6
+	HIGHSCORE_SHIELDING = 7
+	HIGHSCORE_FISHING   = 8
+)
+
+const (
+	ACCESS_TOKEN_SCOPE_READ       = 0x01
+	ACCESS_TOKEN_SCOPE_CHARACTERS = 0x02
 )
 
 type (
+	// NOTE(fusion): JSON field names are part of the public API in `api.go` and
+	// must NOT be changed.
 	TWorld struct {
-		Name             string
-		Type             string
-		NumPlayers       int
-		MaxPlayers       int
-		OnlinePeak       int
-		OnlinePeakTimestamp int
-		LastStartup      int
-		LastShutdown     int
+		Name                string `json:"name"`
+		Type                string `json:"type"`
+		NumPlayers          int    `json:"num_players"`
+		MaxPlayers          int    `json:"max_players"`
+		OnlinePeak          int    `json:"online_peak"`
+		OnlinePeakTimestamp int    `json:"online_peak_timestamp"`
+		LastStartup         int    `json:"last_startup"`
+		LastShutdown        int    `json:"last_shutdown"`
 	}
 
 	TAccountSummary struct {
-		AccountID          int
-		Email              string
-		PremiumDays        int
-		PendingPremiumDays int
-		Deleted            bool
-		Characters         []TCharacterSummary
+		AccountID          int                 `json:"account_id"`
+		Email              string              `json:"email"`
+		PremiumDays        int                 `json:"premium_days"`
+		PendingPremiumDays int                 `json:"pending_premium_days"`
+		Deleted            bool                `json:"deleted"`
+		Characters         []TCharacterSummary `json:"characters"`
 	}
 
 	TCharacterSummary struct {
-		Name       string
-		World      string
-		Level      int
-		Profession string
-		Online     bool
-		Deleted    bool
+		Name       string `json:"name"`
+		World      string `json:"world"`
+		Level      int    `json:"level"`
+		Profession string `json:"profession"`
+		Online     bool   `json:"online"`
+		Deleted    bool   `json:"deleted"`
+		Hidden     bool   `json:"hidden"`
+	}
+
+	// NOTE(fusion): Only the hash of an access token is ever sent to the query
+	// manager so the token itself is never stored anywhere.
+	TAccessToken struct {
+		TokenID  int
+		Name     string
+		Scopes   int
+		Created  int
+		LastUsed int
 	}
 
 	TCharacterProfile struct {
-		Name        string
-		World       string
-		Sex         int
-		Guild       string
-		Rank        string
-		Title       string
-		Level       int
-		Profession  string
-		Residence   string
-		LastLogin   int
-		PremiumDays int
-		Online      bool
-		Deleted     bool
+		Name            string            `json:"name"`
+		World           string            `json:"world"`
+		Sex             int               `json:"sex"`
+		Guild           string            `json:"guild"`
+		Rank            string            `json:"rank"`
+		Title           string            `json:"title"`
+		Level           int               `json:"level"`
+		Profession      string            `json:"profession"`
+		Residence       string            `json:"residence"`
+		LastLogin       int               `json:"last_login"`
+		PremiumDays     int               `json:"-"`
+		Online          bool              `json:"online"`
+		Deleted         bool              `json:"deleted"`
+		OtherCharacters []TOtherCharacter `json:"other_characters"`
+		Deaths          []TCharacterDeath `json:"deaths"`
+		DeathsFailed    bool              `json:"-"`
+	}
+
+	TOtherCharacter struct {
+		Name   string `json:"name"`
+		World  string `json:"world"`
+		Online bool   `json:"online"`
+	}
+
+	TCharacterDeath struct {
+		Timestamp int                `json:"timestamp"`
+		Level     int                `json:"level"`
+		Killers   []TCharacterKiller `json:"killers"`
+	}
+
+	TCharacterKiller struct {
+		Name        string `json:"name"`
+		Player      bool   `json:"player"`
+		Unjustified bool   `json:"unjustified"`
+	}
+
+	TWorldDeath struct {
+		CharacterName string
+		Timestamp     int
+		Level         int
+		Killers       []TCharacterKiller
 	}
 
 	TKillStatistics struct {
-		RaceName      string
-		TimesKilled   int
-		PlayersKilled int
+		RaceName      string `json:"race_name"`
+		TimesKilled   int    `json:"times_killed"`
+		PlayersKilled int    `json:"players_killed"`
 	}
 
-	TOnlineCharacter struct {
+	TGuildSummary struct {
+		Name       string
+		NumMembers int
+	}
+
+	TGuild struct {
+		Name  string
+		World string
+		Ranks []TGuildRank
+	}
+
+	TGuildRank struct {
+		Name    string
+		Members []TGuildMember
+	}
+
+	TGuildMember struct {
 		Name       string
+		Title      string
 		Level      int
 		Profession string
+		Online     bool
 	}
 
-	TAccountCacheEntry struct {
-		AccountID  int
-		Result     int
-		Data       TAccountSummary
-		LastAccess time.Time
+	THouse struct {
+		HouseID   int
+		Name      string
+		Town      string
+		Size      int
+		Rent      int
+		Owner     string
+		PaidUntil int
 	}
 
-	TCharacterCacheEntry struct {
-		CharacterName string
-		Result        int
-		Data          TCharacterProfile
-		LastAccess    time.Time
+	THighscoreEntry struct {
+		Rank       int
+		Name       string
+		Level      int
+		Profession string
+		Value      int
+	}
+
+	TOnlineCharacter struct {
+		Name       string `json:"name"`
+		Level      int    `json:"level"`
+		Profession string `json:"profession"`
 	}
 
-	TKillStatisticsCacheEntry struct {
-		World       string
-		Data        []TKillStatistics
-		RefreshTime time.Time
+	TCachedResult[V any] struct {
+		Result int
+		Data   V
 	}
 
-	TOnlineCharactersCacheEntry struct {
-		World       string
-		Data        []TOnlineCharacter
-		RefreshTime time.Time
+	THighscoresCacheKey struct {
+		World    string
+		Category int
 	}
 
 	TQueryManagerConnection struct {
 		Handle net.Conn
 	}
+
+	// NOTE(fusion): Returned by `ExecuteQuery` when the query couldn't complete
+	// due to a transport problem, as opposed to the query manager answering with
+	// `QUERY_STATUS_ERROR` or `QUERY_STATUS_FAILED`.
+	TQueryError struct {
+		Op  string
+		Err error
+	}
+)
+
+var (
+	ErrQueryManagerNotConnected = errors.New("not connected")
+	ErrQueryInvalidResponse     = errors.New("invalid response")
 )
 
+func (QueryError *TQueryError) Error() string {
+	return fmt.Sprintf("query manager %v: %v", QueryError.Op, QueryError.Err)
+}
+
+func (QueryError *TQueryError) Unwrap() error {
+	return QueryError.Err
+}
+
+func (QueryError *TQueryError) Timeout() bool {
+	return errors.Is(QueryError.Err, os.ErrDeadlineExceeded)
+}
+
+func IsQueryTimeout(Err error) bool {
+	var QueryError *TQueryError
+	return errors.As(Err, &QueryError) && QueryError.Timeout()
+}
+
 func (Connection *TQueryManagerConnection) Connect() bool {
 	if Connection.Handle != nil {
 		g_LogErr.Print("Already connected")
@@ -136,9 +274,10 @@ func (Connection *TQueryManagerConnection) Connect() bool {
 
 	var Err error
 	QueryManagerAddress := JoinHostPort(g_QueryManagerHost, g_QueryManagerPort)
-	Connection.Handle, Err = net.Dial("tcp4", QueryManagerAddress)
+	Connection.Handle, Err = net.DialTimeout("tcp4", QueryManagerAddress, g_QueryManagerTimeout)
 	if Err != nil {
 		g_LogErr.Print(Err)
+		SetQueryManagerDegraded(true)
 		return false
 	}
 
@@ -146,10 +285,16 @@ func (Connection *TQueryManagerConnection) Connect() bool {
 	WriteBuffer := Connection.PrepareQuery(QUERY_LOGIN, LoginBuffer[:])
 	WriteBuffer.Write8(APPLICATION_TYPE_WEB)
 	WriteBuffer.WriteString(g_QueryManagerPassword)
-	Status, _ := Connection.ExecuteQuery(false, &WriteBuffer)
-	if Status != QUERY_STATUS_OK {
+	Status, _, Err := Connection.ExecuteQuery(false, &WriteBuffer)
+	if Err != nil {
+		Connection.Disconnect()
+		g_LogErr.Printf("Failed to login to query manager: %v", Err)
+		SetQueryManagerDegraded(true)
+		return false
+	} else if Status != QUERY_STATUS_OK {
 		Connection.Disconnect()
 		g_LogErr.Printf("Failed to login to query manager (%v)", Status)
+		SetQueryManagerDegraded(true)
 		return false
 	}
 
@@ -172,7 +317,7 @@ func (Connection *TQueryManagerConnection) PrepareQuery(QueryType int, Buffer []
 	return WriteBuffer
 }
 
-func (Connection *TQueryManagerConnection) ExecuteQuery(AutoReconnect bool, WriteBuffer *TWriteBuffer) (Status int, ReadBuffer TReadBuffer) {
+func (Connection *TQueryManagerConnection) ExecuteQuery(AutoReconnect bool, WriteBuffer *TWriteBuffer) (Status int, ReadBuffer TReadBuffer, Err error) {
 	// IMPORTANT(fusion): Different from the C++ version, there is no connection
 	// buffer, and the response is read into the same buffer used by `WriteBuffer`,
 	// to avoid moving data around when reconnecting in the middle of a query.
@@ -192,7 +337,7 @@ func (Connection *TQueryManagerConnection) ExecuteQuery(AutoReconnect bool, Writ
 
 	Status = QUERY_STATUS_FAILED
 	if WriteBuffer.Overflowed() {
-		g_LogErr.Print("Write buffer overflowed")
+		Err = &TQueryError{Op: "write", Err: errors.New("write buffer overflowed")}
 		return
 	}
 
@@ -200,58 +345,45 @@ func (Connection *TQueryManagerConnection) ExecuteQuery(AutoReconnect bool, Writ
 	Buffer := WriteBuffer.Buffer
 	WriteSize := WriteBuffer.Position
 	for Attempt := 1; true; Attempt += 1 {
-		if Connection.Handle == nil && (!AutoReconnect || !Connection.Connect()) {
-			return
-		}
-
-		if _, Err := Connection.Handle.Write(Buffer[:WriteSize]); Err != nil {
-			Connection.Disconnect()
-			if Attempt >= MaxAttempts {
-				g_LogErr.Printf("Failed to write request: %v", Err)
+		if Connection.Handle == nil {
+			if !AutoReconnect || !Connection.Connect() {
+				Err = &TQueryError{Op: "connect", Err: ErrQueryManagerNotConnected}
 				return
 			}
-			continue
 		}
 
-		var Help [4]byte
-		if _, Err := Connection.Handle.Read(Help[:2]); Err != nil {
+		// NOTE(fusion): A failed or timed out query drops the connection and is
+		// retried once on a fresh one. It doesn't put the website into degraded
+		// mode by itself, since a single slow query (e.g. a large highscores
+		// reply) says little about the query manager being reachable. That is
+		// left to `Connect`, when the retry can't even get a new connection.
+		if Err = Connection.WriteFrame(Buffer[:WriteSize]); Err != nil {
 			Connection.Disconnect()
-			if Attempt >= MaxAttempts {
-				g_LogErr.Printf("Failed to read response size: %v", Err)
+			if Attempt >= MaxAttempts || !AutoReconnect {
 				return
 			}
 			continue
 		}
 
-		ResponseSize := int(binary.LittleEndian.Uint16(Help[:2]))
-		if ResponseSize == 0xFFFF {
-			if _, Err := Connection.Handle.Read(Help[:]); Err != nil {
-				Connection.Disconnect()
-				g_LogErr.Printf("Failed to read response extended size: %v", Err)
+		var ResponseSize int
+		if ResponseSize, Err = Connection.ReadFrame(Buffer); Err != nil {
+			// IMPORTANT(fusion): An invalid response (e.g. one that doesn't fit
+			// into the caller's buffer) is specific to that query and would be
+			// just as invalid the second time, so it isn't retried.
+			Connection.Disconnect()
+			if Attempt >= MaxAttempts || !AutoReconnect ||
+				errors.Is(Err, ErrQueryInvalidResponse) {
 				return
 			}
-
-			ResponseSize = int(binary.LittleEndian.Uint32(Help[:]))
-		}
-
-		if ResponseSize <= 0 || ResponseSize > len(Buffer) {
-			Connection.Disconnect()
-			g_LogErr.Printf("Invalid response size %v (BufferSize: %v)",
-				ResponseSize, len(Buffer))
-			return
-		}
-
-		if _, Err := Connection.Handle.Read(Buffer[:ResponseSize]); Err != nil {
-			Connection.Disconnect()
-			g_LogErr.Printf("Failed to read response: %v", Err)
-			return
+			continue
 		}
 
 		ReadBuffer = TReadBuffer{
-			Buffer:   Buffer,
+			Buffer:   Buffer[:ResponseSize],
 			Position: 0,
 		}
 		Status = int(ReadBuffer.Read8())
+		SetQueryManagerDegraded(false)
 		return
 	}
 
@@ -261,20 +393,92 @@ func (Connection *TQueryManagerConnection) ExecuteQuery(AutoReconnect bool, Writ
 	return
 }
 
+// NOTE(fusion): Both functions below expect the full frame, including the size
+// header, to be written by `ExecuteQuery` or returned by the query manager. If
+// `g_QueryManagerTimeout` is set, each operation is bounded by it, so a stalled
+// query manager can't hold onto a connection (and the request using it) forever.
+func (Connection *TQueryManagerConnection) WriteFrame(Frame []byte) error {
+	if g_QueryManagerTimeout > 0 {
+		Deadline := time.Now().Add(g_QueryManagerTimeout)
+		if Err := Connection.Handle.SetWriteDeadline(Deadline); Err != nil {
+			return &TQueryError{Op: "write", Err: Err}
+		}
+	}
+
+	if _, Err := Connection.Handle.Write(Frame); Err != nil {
+		return &TQueryError{Op: "write", Err: Err}
+	}
+
+	return nil
+}
+
+func (Connection *TQueryManagerConnection) ReadFrame(Buffer []byte) (int, error) {
+	if g_QueryManagerTimeout > 0 {
+		Deadline := time.Now().Add(g_QueryManagerTimeout)
+		if Err := Connection.Handle.SetReadDeadline(Deadline); Err != nil {
+			return 0, &TQueryError{Op: "read", Err: Err}
+		}
+	}
+
+	var Help [4]byte
+	if _, Err := io.ReadFull(Connection.Handle, Help[:2]); Err != nil {
+		return 0, &TQueryError{Op: "read size", Err: Err}
+	}
+
+	ResponseSize := int(binary.LittleEndian.Uint16(Help[:2]))
+	if ResponseSize == 0xFFFF {
+		if _, Err := io.ReadFull(Connection.Handle, Help[:]); Err != nil {
+			if Err == io.EOF {
+				Err = io.ErrUnexpectedEOF
+			}
+			return 0, &TQueryError{Op: "read extended size", Err: Err}
+		}
+
+		ResponseSize = int(binary.LittleEndian.Uint32(Help[:]))
+	}
+
+	if ResponseSize <= 0 || ResponseSize > len(Buffer) {
+		return 0, &TQueryError{Op: "read",
+			Err: fmt.Errorf("%w size %v (BufferSize: %v)",
+				ErrQueryInvalidResponse, ResponseSize, len(Buffer))}
+	}
+
+	if _, Err := io.ReadFull(Connection.Handle, Buffer[:ResponseSize]); Err != nil {
+		if Err == io.EOF {
+			Err = io.ErrUnexpectedEOF
+		}
+		return 0, &TQueryError{Op: "read response", Err: Err}
+	}
+
+	return ResponseSize, nil
+}
+
+func (Connection *TQueryManagerConnection) Ping() bool {
+	// NOTE(fusion): There is no dedicated ping query so we use the world list
+	// which is small and always available.
+	Result, _ := Connection.GetWorlds()
+	return Result == 0
+}
+
 func (Connection *TQueryManagerConnection) CheckAccountPassword(AccountID int, Password, IPAddress string) (Result int) {
 	var Buffer [1024]byte
 	WriteBuffer := Connection.PrepareQuery(QUERY_CHECK_ACCOUNT_PASSWORD, Buffer[:])
 	WriteBuffer.Write32(uint32(AccountID))
 	WriteBuffer.WriteString(Password)
 	WriteBuffer.WriteString(IPAddress)
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
 	case QUERY_STATUS_ERROR:
 		ErrorCode := int(ReadBuffer.Read8())
-		if ErrorCode >= 1 && ErrorCode <= 4 {
+		if ErrorCode >= 1 && ErrorCode <= 7 {
 			Result = ErrorCode
 		} else {
 			g_LogErr.Printf("Invalid error code %v", ErrorCode)
@@ -285,14 +489,20 @@ func (Connection *TQueryManagerConnection) CheckAccountPassword(AccountID int, P
 	return
 }
 
-func (Connection *TQueryManagerConnection) CreateAccount(AccountID int, Email string, Password string) (Result int) {
+func (Connection *TQueryManagerConnection) CreateAccount(AccountID int, Email string, Password string, Activated bool) (Result int) {
 	var Buffer [1024]byte
 	WriteBuffer := Connection.PrepareQuery(QUERY_CREATE_ACCOUNT, Buffer[:])
 	WriteBuffer.Write32(uint32(AccountID))
 	WriteBuffer.WriteString(Email)
 	WriteBuffer.WriteString(Password)
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+	WriteBuffer.WriteFlag(Activated)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
@@ -309,21 +519,26 @@ func (Connection *TQueryManagerConnection) CreateAccount(AccountID int, Email st
 	return
 }
 
-func (Connection *TQueryManagerConnection) CreateCharacter(World string, AccountID int, Name string, Sex int) (Result int) {
+func (Connection *TQueryManagerConnection) RequestAccountRecovery(Email string, IPAddress string) (Result int, AccountID int, Token string) {
 	var Buffer [1024]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_CREATE_CHARACTER, Buffer[:])
-	WriteBuffer.WriteString(World)
-	WriteBuffer.Write32(uint32(AccountID))
-	WriteBuffer.WriteString(Name)
-	WriteBuffer.Write8(uint8(Sex))
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+	WriteBuffer := Connection.PrepareQuery(QUERY_REQUEST_ACCOUNT_RECOVERY, Buffer[:])
+	WriteBuffer.WriteString(Email)
+	WriteBuffer.WriteString(IPAddress)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
+		AccountID = int(ReadBuffer.Read32())
+		Token = ReadBuffer.ReadString()
 	case QUERY_STATUS_ERROR:
 		ErrorCode := int(ReadBuffer.Read8())
-		if ErrorCode >= 1 && ErrorCode <= 3 {
+		if ErrorCode == 1 {
 			Result = ErrorCode
 		} else {
 			g_LogErr.Printf("Invalid error code %v", ErrorCode)
@@ -334,35 +549,25 @@ func (Connection *TQueryManagerConnection) CreateCharacter(World string, Account
 	return
 }
 
-func (Connection *TQueryManagerConnection) GetAccountSummary(AccountID int) (Result int, Account TAccountSummary) {
-	var Buffer [16384]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_GET_ACCOUNT_SUMMARY, Buffer[:])
-	WriteBuffer.Write32(uint32(AccountID))
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+func (Connection *TQueryManagerConnection) RecoverAccount(Token string, Password string) (Result int, AccountID int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_RECOVER_ACCOUNT, Buffer[:])
+	WriteBuffer.WriteString(Token)
+	WriteBuffer.WriteString(Password)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
-		Account.AccountID = AccountID
-		Account.Email = ReadBuffer.ReadString()
-		Account.PremiumDays = int(ReadBuffer.Read16())
-		Account.PendingPremiumDays = int(ReadBuffer.Read16())
-		Account.Deleted = ReadBuffer.ReadFlag()
-		NumCharacters := int(ReadBuffer.Read8())
-		if NumCharacters > 0 {
-			Account.Characters = make([]TCharacterSummary, NumCharacters)
-			for Index := range Account.Characters {
-				Account.Characters[Index].Name = ReadBuffer.ReadString()
-				Account.Characters[Index].World = ReadBuffer.ReadString()
-				Account.Characters[Index].Level = int(ReadBuffer.Read16())
-				Account.Characters[Index].Profession = ReadBuffer.ReadString()
-				Account.Characters[Index].Online = ReadBuffer.ReadFlag()
-				Account.Characters[Index].Deleted = ReadBuffer.ReadFlag()
-			}
-		}
+		AccountID = int(ReadBuffer.Read32())
 	case QUERY_STATUS_ERROR:
 		ErrorCode := int(ReadBuffer.Read8())
-		if ErrorCode >= 1 && ErrorCode <= 4 {
+		if ErrorCode == 1 {
 			Result = ErrorCode
 		} else {
 			g_LogErr.Printf("Invalid error code %v", ErrorCode)
@@ -373,28 +578,21 @@ func (Connection *TQueryManagerConnection) GetAccountSummary(AccountID int) (Res
 	return
 }
 
-func (Connection *TQueryManagerConnection) GetCharacterProfile(CharacterName string) (Result int, Character TCharacterProfile) {
-	var Buffer [16384]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_GET_CHARACTER_PROFILE, Buffer[:])
-	WriteBuffer.WriteString(CharacterName)
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+func (Connection *TQueryManagerConnection) ChangePassword(AccountID int, Password string) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_CHANGE_PASSWORD, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(Password)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
-		Character.Name = ReadBuffer.ReadString()
-		Character.World = ReadBuffer.ReadString()
-		Character.Sex = int(ReadBuffer.Read8())
-		Character.Guild = ReadBuffer.ReadString()
-		Character.Rank = ReadBuffer.ReadString()
-		Character.Title = ReadBuffer.ReadString()
-		Character.Level = int(ReadBuffer.Read16())
-		Character.Profession = ReadBuffer.ReadString()
-		Character.Residence = ReadBuffer.ReadString()
-		Character.LastLogin = int(ReadBuffer.Read32())
-		Character.PremiumDays = int(ReadBuffer.Read16())
-		Character.Online = ReadBuffer.ReadFlag()
-		Character.Deleted = ReadBuffer.ReadFlag()
 	case QUERY_STATUS_ERROR:
 		ErrorCode := int(ReadBuffer.Read8())
 		if ErrorCode == 1 {
@@ -408,27 +606,27 @@ func (Connection *TQueryManagerConnection) GetCharacterProfile(CharacterName str
 	return
 }
 
-func (Connection *TQueryManagerConnection) GetWorlds() (Result int, Worlds []TWorld) {
-	var Buffer [16384]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_GET_WORLDS, Buffer[:])
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+func (Connection *TQueryManagerConnection) ChangeEmail(AccountID int, Email string) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_CHANGE_EMAIL, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(Email)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
-		NumWorlds := int(ReadBuffer.Read8())
-		if NumWorlds > 0 {
-			Worlds = make([]TWorld, NumWorlds)
-			for Index := range Worlds {
-				Worlds[Index].Name = ReadBuffer.ReadString()
-				Worlds[Index].Type = WorldTypeString(int(ReadBuffer.Read8()))
-				Worlds[Index].NumPlayers = int(ReadBuffer.Read16())
-				Worlds[Index].MaxPlayers = int(ReadBuffer.Read16())
-				Worlds[Index].OnlinePeak = int(ReadBuffer.Read16())
-				Worlds[Index].OnlinePeakTimestamp = int(ReadBuffer.Read32())
-				Worlds[Index].LastStartup = int(ReadBuffer.Read32())
-				Worlds[Index].LastShutdown = int(ReadBuffer.Read32())
-			}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 2 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
 		}
 	default:
 		g_LogErr.Printf("Request failed (%v)", Status)
@@ -436,23 +634,26 @@ func (Connection *TQueryManagerConnection) GetWorlds() (Result int, Worlds []TWo
 	return
 }
 
-func (Connection *TQueryManagerConnection) GetOnlineCharacters(World string) (Result int, Characters []TOnlineCharacter) {
-	var Buffer [65536]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_GET_ONLINE_CHARACTERS, Buffer[:])
-	WriteBuffer.WriteString(World)
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+func (Connection *TQueryManagerConnection) ActivateAccount(AccountID int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_ACTIVATE_ACCOUNT, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
-		NumCharacters := int(ReadBuffer.Read16())
-		if NumCharacters > 0 {
-			Characters = make([]TOnlineCharacter, NumCharacters)
-			for Index := 0; Index < NumCharacters; Index += 1 {
-				Characters[Index].Name = ReadBuffer.ReadString()
-				Characters[Index].Level = int(ReadBuffer.Read16())
-				Characters[Index].Profession = ReadBuffer.ReadString()
-			}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
 		}
 	default:
 		g_LogErr.Printf("Request failed (%v)", Status)
@@ -460,23 +661,26 @@ func (Connection *TQueryManagerConnection) GetOnlineCharacters(World string) (Re
 	return
 }
 
-func (Connection *TQueryManagerConnection) GetKillStatistics(World string) (Result int, Stats []TKillStatistics) {
-	var Buffer [65536]byte
-	WriteBuffer := Connection.PrepareQuery(QUERY_GET_KILL_STATISTICS, Buffer[:])
-	WriteBuffer.WriteString(World)
-	Status, ReadBuffer := Connection.ExecuteQuery(true, &WriteBuffer)
+func (Connection *TQueryManagerConnection) DeleteAccount(AccountID int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_DELETE_ACCOUNT, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
 	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
 	switch Status {
 	case QUERY_STATUS_OK:
 		Result = 0
-		NumStats := int(ReadBuffer.Read16())
-		if NumStats > 0 {
-			Stats = make([]TKillStatistics, NumStats)
-			for Index := 0; Index < NumStats; Index += 1 {
-				Stats[Index].RaceName = ReadBuffer.ReadString()
-				Stats[Index].PlayersKilled = int(ReadBuffer.Read32())
-				Stats[Index].TimesKilled = int(ReadBuffer.Read32())
-			}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 2 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
 		}
 	default:
 		g_LogErr.Printf("Request failed (%v)", Status)
@@ -484,178 +688,1269 @@ func (Connection *TQueryManagerConnection) GetKillStatistics(World string) (Resu
 	return
 }
 
-// Query Subsystem
-// ==============================================================================
-var (
-	g_QueryManagerMutex      sync.Mutex
-	g_QueryManagerConnection TQueryManagerConnection
-
-	g_AccountCache          []TAccountCacheEntry
-	g_CharacterCache        []TCharacterCacheEntry
-	g_WorldCache            []TWorld
-	g_WorldCacheRefreshTime time.Time
-	g_OnlineCharactersCache []TOnlineCharactersCacheEntry
-	g_KillStatisticsCache   []TKillStatisticsCacheEntry
-)
-
-func InitQuery() bool {
-	g_Log.Printf("QueryManagerHost: %v", g_QueryManagerHost)
-	g_Log.Printf("QueryManagerPort: %v", g_QueryManagerPort)
-	g_Log.Printf("MaxCachedAccounts: %v", g_MaxCachedAccounts)
-	g_Log.Printf("MaxCachedCharacters: %v", g_MaxCachedCharacters)
-	g_Log.Printf("CharacterRefreshInterval: %v", g_CharacterRefreshInterval)
-	g_Log.Printf("WorldRefreshInterval: %v", g_WorldRefreshInterval)
+func (Connection *TQueryManagerConnection) UndeleteAccount(AccountID int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_UNDELETE_ACCOUNT, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) CreateCharacter(World string, AccountID int, Name string, Sex int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_CREATE_CHARACTER, Buffer[:])
+	WriteBuffer.WriteString(World)
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(Name)
+	WriteBuffer.Write8(uint8(Sex))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 3 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) DeleteCharacter(AccountID int, CharacterName string) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_DELETE_CHARACTER, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(CharacterName)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 2 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) UndeleteCharacter(AccountID int, CharacterName string) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_UNDELETE_CHARACTER, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(CharacterName)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+// IMPORTANT(fusion): Listing other characters on profiles is opt-in, so the query
+// manager is expected to create characters with the hidden flag already set.
+func (Connection *TQueryManagerConnection) SetCharacterHidden(AccountID int, CharacterName string, Hidden bool) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_SET_CHARACTER_HIDDEN, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(CharacterName)
+	WriteBuffer.WriteFlag(Hidden)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) CreateAccessToken(AccountID int, Name string, TokenHash string, Scopes int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_CREATE_ACCESS_TOKEN, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.WriteString(Name)
+	WriteBuffer.WriteString(TokenHash)
+	WriteBuffer.Write8(uint8(Scopes))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 2 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetAccessTokens(AccountID int) (Result int, Tokens []TAccessToken) {
+	var Buffer [16384]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_ACCESS_TOKENS, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumTokens := int(ReadBuffer.Read8())
+		if NumTokens > 0 {
+			Tokens = make([]TAccessToken, NumTokens)
+			for Index := range Tokens {
+				Tokens[Index].TokenID = int(ReadBuffer.Read32())
+				Tokens[Index].Name = ReadBuffer.ReadString()
+				Tokens[Index].Scopes = int(ReadBuffer.Read8())
+				Tokens[Index].Created = int(ReadBuffer.Read32())
+				Tokens[Index].LastUsed = int(ReadBuffer.Read32())
+			}
+		}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) RevokeAccessToken(AccountID int, TokenID int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_REVOKE_ACCESS_TOKEN, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	WriteBuffer.Write32(uint32(TokenID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) RevokeAllAccessTokens(AccountID int) (Result int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_REVOKE_ALL_ACCESS_TOKENS, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, _, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) CheckAccessToken(TokenHash string) (Result int, AccountID int, Scopes int) {
+	var Buffer [1024]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_CHECK_ACCESS_TOKEN, Buffer[:])
+	WriteBuffer.WriteString(TokenHash)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		AccountID = int(ReadBuffer.Read32())
+		Scopes = int(ReadBuffer.Read8())
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetAccountSummary(AccountID int) (Result int, Account TAccountSummary) {
+	var Buffer [16384]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_ACCOUNT_SUMMARY, Buffer[:])
+	WriteBuffer.Write32(uint32(AccountID))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		Account.AccountID = AccountID
+		Account.Email = ReadBuffer.ReadString()
+		Account.PremiumDays = int(ReadBuffer.Read16())
+		Account.PendingPremiumDays = int(ReadBuffer.Read16())
+		Account.Deleted = ReadBuffer.ReadFlag()
+		NumCharacters := int(ReadBuffer.Read8())
+		if NumCharacters > 0 {
+			Account.Characters = make([]TCharacterSummary, NumCharacters)
+			for Index := range Account.Characters {
+				Account.Characters[Index].Name = ReadBuffer.ReadString()
+				Account.Characters[Index].World = ReadBuffer.ReadString()
+				Account.Characters[Index].Level = int(ReadBuffer.Read16())
+				Account.Characters[Index].Profession = ReadBuffer.ReadString()
+				Account.Characters[Index].Online = ReadBuffer.ReadFlag()
+				Account.Characters[Index].Deleted = ReadBuffer.ReadFlag()
+				Account.Characters[Index].Hidden = ReadBuffer.ReadFlag()
+			}
+		}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode >= 1 && ErrorCode <= 4 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetCharacterProfile(CharacterName string) (Result int, Character TCharacterProfile) {
+	var Buffer [16384]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_CHARACTER_PROFILE, Buffer[:])
+	WriteBuffer.WriteString(CharacterName)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		Character.Name = ReadBuffer.ReadString()
+		Character.World = ReadBuffer.ReadString()
+		Character.Sex = int(ReadBuffer.Read8())
+		Character.Guild = ReadBuffer.ReadString()
+		Character.Rank = ReadBuffer.ReadString()
+		Character.Title = ReadBuffer.ReadString()
+		Character.Level = int(ReadBuffer.Read16())
+		Character.Profession = ReadBuffer.ReadString()
+		Character.Residence = ReadBuffer.ReadString()
+		Character.LastLogin = int(ReadBuffer.Read32())
+		Character.PremiumDays = int(ReadBuffer.Read16())
+		Character.Online = ReadBuffer.ReadFlag()
+		Character.Deleted = ReadBuffer.ReadFlag()
+		NumOtherCharacters := int(ReadBuffer.Read8())
+		if NumOtherCharacters > 0 {
+			Character.OtherCharacters = make([]TOtherCharacter, NumOtherCharacters)
+			for Index := range Character.OtherCharacters {
+				Character.OtherCharacters[Index].Name = ReadBuffer.ReadString()
+				Character.OtherCharacters[Index].World = ReadBuffer.ReadString()
+				Character.OtherCharacters[Index].Online = ReadBuffer.ReadFlag()
+			}
+		}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func ReadCharacterKillers(ReadBuffer *TReadBuffer) (Killers []TCharacterKiller) {
+	NumKillers := int(ReadBuffer.Read8())
+	if NumKillers > 0 {
+		Killers = make([]TCharacterKiller, NumKillers)
+		for Index := range Killers {
+			Killers[Index].Name = ReadBuffer.ReadString()
+			Killers[Index].Player = ReadBuffer.ReadFlag()
+			Killers[Index].Unjustified = ReadBuffer.ReadFlag()
+		}
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetCharacterDeaths(CharacterName string, MaxDeaths int) (Result int, Deaths []TCharacterDeath) {
+	var Buffer [16384]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_CHARACTER_DEATHS, Buffer[:])
+	WriteBuffer.WriteString(CharacterName)
+	WriteBuffer.Write8(uint8(MaxDeaths))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumDeaths := int(ReadBuffer.Read8())
+		if NumDeaths > 0 {
+			Deaths = make([]TCharacterDeath, NumDeaths)
+			for Index := range Deaths {
+				Deaths[Index].Timestamp = int(ReadBuffer.Read32())
+				Deaths[Index].Level = int(ReadBuffer.Read16())
+				Deaths[Index].Killers = ReadCharacterKillers(&ReadBuffer)
+			}
+		}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetWorlds() (Result int, Worlds []TWorld) {
+	var Buffer [16384]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_WORLDS, Buffer[:])
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumWorlds := int(ReadBuffer.Read8())
+		if NumWorlds > 0 {
+			Worlds = make([]TWorld, NumWorlds)
+			for Index := range Worlds {
+				Worlds[Index].Name = ReadBuffer.ReadString()
+				Worlds[Index].Type = WorldTypeString(int(ReadBuffer.Read8()))
+				Worlds[Index].NumPlayers = int(ReadBuffer.Read16())
+				Worlds[Index].MaxPlayers = int(ReadBuffer.Read16())
+				Worlds[Index].OnlinePeak = int(ReadBuffer.Read16())
+				Worlds[Index].OnlinePeakTimestamp = int(ReadBuffer.Read32())
+				Worlds[Index].LastStartup = int(ReadBuffer.Read32())
+				Worlds[Index].LastShutdown = int(ReadBuffer.Read32())
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetOnlineCharacters(World string) (Result int, Characters []TOnlineCharacter) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_ONLINE_CHARACTERS, Buffer[:])
+	WriteBuffer.WriteString(World)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
 
-	Result := g_QueryManagerConnection.Connect()
-	if !Result {
-		g_LogErr.Print("Failed to connect to query manager")
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumCharacters := int(ReadBuffer.Read16())
+		if NumCharacters > 0 {
+			Characters = make([]TOnlineCharacter, NumCharacters)
+			for Index := 0; Index < NumCharacters; Index += 1 {
+				Characters[Index].Name = ReadBuffer.ReadString()
+				Characters[Index].Level = int(ReadBuffer.Read16())
+				Characters[Index].Profession = ReadBuffer.ReadString()
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetKillStatistics(World string) (Result int, Stats []TKillStatistics) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_KILL_STATISTICS, Buffer[:])
+	WriteBuffer.WriteString(World)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumStats := int(ReadBuffer.Read16())
+		if NumStats > 0 {
+			Stats = make([]TKillStatistics, NumStats)
+			for Index := 0; Index < NumStats; Index += 1 {
+				Stats[Index].RaceName = ReadBuffer.ReadString()
+				Stats[Index].PlayersKilled = int(ReadBuffer.Read32())
+				Stats[Index].TimesKilled = int(ReadBuffer.Read32())
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
+}
+
+func (Connection *TQueryManagerConnection) GetHighscores(World string, Category int, MaxEntries int) (Result int, Entries []THighscoreEntry) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_HIGHSCORES, Buffer[:])
+	WriteBuffer.WriteString(World)
+	WriteBuffer.Write8(uint8(Category))
+	WriteBuffer.Write16(uint16(MaxEntries))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumEntries := int(ReadBuffer.Read16())
+		if NumEntries > 0 {
+			Entries = make([]THighscoreEntry, NumEntries)
+			for Index := 0; Index < NumEntries; Index += 1 {
+				Entries[Index].Rank = Index + 1
+				Entries[Index].Name = ReadBuffer.ReadString()
+				Entries[Index].Level = int(ReadBuffer.Read16())
+				Entries[Index].Profession = ReadBuffer.ReadString()
+				Entries[Index].Value = int(ReadBuffer.Read32())
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
 	}
-	return Result
+	return
 }
 
-func ExitQuery() {
-	g_QueryManagerConnection.Disconnect()
+func (Connection *TQueryManagerConnection) GetGuilds(World string) (Result int, Guilds []TGuildSummary) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_GUILDS, Buffer[:])
+	WriteBuffer.WriteString(World)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumGuilds := int(ReadBuffer.Read16())
+		if NumGuilds > 0 {
+			Guilds = make([]TGuildSummary, NumGuilds)
+			for Index := 0; Index < NumGuilds; Index += 1 {
+				Guilds[Index].Name = ReadBuffer.ReadString()
+				Guilds[Index].NumMembers = int(ReadBuffer.Read16())
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
 }
 
-func CheckAccountPassword(AccountID int, Password, IPAddress string) int {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-	return g_QueryManagerConnection.CheckAccountPassword(AccountID, Password, IPAddress)
+func (Connection *TQueryManagerConnection) GetGuild(GuildName string) (Result int, Guild TGuild) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_GUILD, Buffer[:])
+	WriteBuffer.WriteString(GuildName)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		Guild.Name = ReadBuffer.ReadString()
+		Guild.World = ReadBuffer.ReadString()
+		NumRanks := int(ReadBuffer.Read8())
+		if NumRanks > 0 {
+			Guild.Ranks = make([]TGuildRank, NumRanks)
+			for Index := range Guild.Ranks {
+				Rank := &Guild.Ranks[Index]
+				Rank.Name = ReadBuffer.ReadString()
+				NumMembers := int(ReadBuffer.Read16())
+				if NumMembers > 0 {
+					Rank.Members = make([]TGuildMember, NumMembers)
+					for MemberIndex := range Rank.Members {
+						Rank.Members[MemberIndex].Name = ReadBuffer.ReadString()
+						Rank.Members[MemberIndex].Title = ReadBuffer.ReadString()
+						Rank.Members[MemberIndex].Level = int(ReadBuffer.Read16())
+						Rank.Members[MemberIndex].Profession = ReadBuffer.ReadString()
+						Rank.Members[MemberIndex].Online = ReadBuffer.ReadFlag()
+					}
+				}
+			}
+		}
+	case QUERY_STATUS_ERROR:
+		ErrorCode := int(ReadBuffer.Read8())
+		if ErrorCode == 1 {
+			Result = ErrorCode
+		} else {
+			g_LogErr.Printf("Invalid error code %v", ErrorCode)
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
 }
 
-func CreateAccount(AccountID int, Email string, Password string) int {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-	return g_QueryManagerConnection.CreateAccount(AccountID, Email, Password)
+func (Connection *TQueryManagerConnection) GetHouses(World string) (Result int, Houses []THouse) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_HOUSES, Buffer[:])
+	WriteBuffer.WriteString(World)
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumHouses := int(ReadBuffer.Read16())
+		if NumHouses > 0 {
+			Houses = make([]THouse, NumHouses)
+			for Index := 0; Index < NumHouses; Index += 1 {
+				Houses[Index].HouseID = int(ReadBuffer.Read16())
+				Houses[Index].Name = ReadBuffer.ReadString()
+				Houses[Index].Town = ReadBuffer.ReadString()
+				Houses[Index].Size = int(ReadBuffer.Read16())
+				Houses[Index].Rent = int(ReadBuffer.Read32())
+				Houses[Index].Owner = ReadBuffer.ReadString()
+				Houses[Index].PaidUntil = int(ReadBuffer.Read32())
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
 }
 
-func CreateCharacter(World string, AccountID int, Name string, Sex int) int {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-	return g_QueryManagerConnection.CreateCharacter(World, AccountID, Name, Sex)
+func (Connection *TQueryManagerConnection) GetLatestDeaths(World string, MaxDeaths int) (Result int, Deaths []TWorldDeath) {
+	var Buffer [65536]byte
+	WriteBuffer := Connection.PrepareQuery(QUERY_GET_LATEST_DEATHS, Buffer[:])
+	WriteBuffer.WriteString(World)
+	WriteBuffer.Write16(uint16(MaxDeaths))
+	Status, ReadBuffer, Err := Connection.ExecuteQuery(true, &WriteBuffer)
+	Result = -1
+	if Err != nil {
+		g_LogErr.Printf("Request failed: %v", Err)
+		return
+	}
+
+	switch Status {
+	case QUERY_STATUS_OK:
+		Result = 0
+		NumDeaths := int(ReadBuffer.Read16())
+		if NumDeaths > 0 {
+			Deaths = make([]TWorldDeath, NumDeaths)
+			for Index := 0; Index < NumDeaths; Index += 1 {
+				Deaths[Index].CharacterName = ReadBuffer.ReadString()
+				Deaths[Index].Timestamp = int(ReadBuffer.Read32())
+				Deaths[Index].Level = int(ReadBuffer.Read16())
+				Deaths[Index].Killers = ReadCharacterKillers(&ReadBuffer)
+			}
+		}
+	default:
+		g_LogErr.Printf("Request failed (%v)", Status)
+	}
+	return
 }
 
-func GetAccountSummary(AccountID int) (Result int, Account TAccountSummary) {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
+// Query Subsystem
+// ==============================================================================
+var (
+	// NOTE(fusion): Connections are kept in a buffered channel that acts as the
+	// connection pool. Each cache has its own mutex which is never held while
+	// waiting on the network.
+	g_QueryManagerPool     chan *TQueryManagerConnection
+	g_QueryManagerDegraded atomic.Bool
+	g_QueryBackgroundStop  chan struct{}
+
+	g_AccountCache          *TCache[int, TCachedResult[TAccountSummary]]
+	g_CharacterCache        *TCache[string, TCachedResult[TCharacterProfile]]
+	g_WorldCache            *TCache[struct{}, TCachedResult[[]TWorld]]
+	g_OnlineCharactersCache *TCache[string, TCachedResult[[]TOnlineCharacter]]
+	g_KillStatisticsCache   *TCache[string, TCachedResult[[]TKillStatistics]]
+	g_HighscoresCache       *TCache[THighscoresCacheKey, TCachedResult[[]THighscoreEntry]]
+	g_GuildsCache           *TCache[string, TCachedResult[[]TGuildSummary]]
+	g_GuildCache            *TCache[string, TCachedResult[TGuild]]
+	g_HousesCache           *TCache[string, TCachedResult[[]THouse]]
+	g_LatestDeathsCache     *TCache[string, TCachedResult[[]TWorldDeath]]
+)
 
-	if g_AccountCache == nil {
-		g_AccountCache = make([]TAccountCacheEntry, g_MaxCachedAccounts)
+func InitQuery() bool {
+	g_Log.Printf("QueryManagerHost: %v", g_QueryManagerHost)
+	g_Log.Printf("QueryManagerPort: %v", g_QueryManagerPort)
+	g_Log.Printf("QueryManagerMaxConnections: %v", g_QueryManagerMaxConnections)
+	g_Log.Printf("QueryManagerTimeout: %v", g_QueryManagerTimeout)
+	g_Log.Printf("QueryManagerKeepaliveInterval: %v", g_QueryManagerKeepaliveInterval)
+	g_Log.Printf("MaxCachedAccounts: %v", g_MaxCachedAccounts)
+	g_Log.Printf("MaxCachedCharacters: %v", g_MaxCachedCharacters)
+	g_Log.Printf("AccountCacheTTL: %v", g_AccountCacheTTL)
+	g_Log.Printf("CharacterCacheTTL: %v", g_CharacterCacheTTL)
+	g_Log.Printf("CharacterNotFoundCacheTTL: %v", g_CharacterNotFoundCacheTTL)
+	g_Log.Printf("CharacterErrorCacheTTL: %v", g_CharacterErrorCacheTTL)
+	g_Log.Printf("WorldCacheTTL: %v", g_WorldCacheTTL)
+	g_Log.Printf("OnlineCharactersCacheTTL: %v", g_OnlineCharactersCacheTTL)
+	g_Log.Printf("KillStatisticsCacheTTL: %v", g_KillStatisticsCacheTTL)
+	g_Log.Printf("WorldRefresherInterval: %v", g_WorldRefresherInterval)
+
+	if g_QueryManagerMaxConnections <= 0 {
+		g_LogErr.Printf("Invalid number of query manager connections %v",
+			g_QueryManagerMaxConnections)
+		return false
 	}
 
-	var Entry *TAccountCacheEntry
-	LeastRecentlyUsedIndex := 0
-	LeastRecentlyUsedTime := g_AccountCache[0].LastAccess
-	for Index := 0; Index < len(g_AccountCache); Index += 1 {
-		Current := &g_AccountCache[Index]
+	if g_MaxCachedAccounts <= 0 || g_MaxCachedCharacters <= 0 {
+		g_LogErr.Printf("Invalid cache capacity (MaxCachedAccounts: %v, MaxCachedCharacters: %v)",
+			g_MaxCachedAccounts, g_MaxCachedCharacters)
+		return false
+	}
 
-		// NOTE(fusion): Account data itself shouldn't change over time unless
-		// we do it ourselves, in which case `InvalidateAccountCachedData` is
-		// used to invalidate the cache entry. The problem is that the account
-		// summary also includes character data which will change, depending on
-		// activities on the game server.
-		if time.Since(Current.LastAccess) >= g_CharacterRefreshInterval {
-			*Current = TAccountCacheEntry{}
+	// NOTE(fusion): Other world data (highscores, guilds, houses, etc...) is
+	// not as time sensitive and uses the world TTL. Guild data includes member
+	// levels and online status so it uses the character TTL instead.
+	const MaxCachedWorldData = 256
+	const MaxCachedGuilds = 1024
+	g_AccountCache = NewCache[int, TCachedResult[TAccountSummary]](g_MaxCachedAccounts, g_AccountCacheTTL)
+	g_CharacterCache = NewCache[string, TCachedResult[TCharacterProfile]](g_MaxCachedCharacters, g_CharacterCacheTTL)
+	g_CharacterCache.TTLFunc = CharacterCacheTTL
+	g_WorldCache = NewCache[struct{}, TCachedResult[[]TWorld]](1, g_WorldCacheTTL)
+	g_OnlineCharactersCache = NewCache[string, TCachedResult[[]TOnlineCharacter]](MaxCachedWorldData, g_OnlineCharactersCacheTTL)
+	g_KillStatisticsCache = NewCache[string, TCachedResult[[]TKillStatistics]](MaxCachedWorldData, g_KillStatisticsCacheTTL)
+	g_HighscoresCache = NewCache[THighscoresCacheKey, TCachedResult[[]THighscoreEntry]](MaxCachedWorldData, g_WorldCacheTTL)
+	g_GuildsCache = NewCache[string, TCachedResult[[]TGuildSummary]](MaxCachedWorldData, g_WorldCacheTTL)
+	g_GuildCache = NewCache[string, TCachedResult[TGuild]](MaxCachedGuilds, g_CharacterCacheTTL)
+	g_HousesCache = NewCache[string, TCachedResult[[]THouse]](MaxCachedWorldData, g_WorldCacheTTL)
+	g_LatestDeathsCache = NewCache[string, TCachedResult[[]TWorldDeath]](MaxCachedWorldData, g_WorldCacheTTL)
+
+	// NOTE(fusion): The server is allowed to boot without the query manager.
+	// Connections that fail here are handed to the same reconnect loop used
+	// when they break later on, and pages are served in degraded mode until
+	// the query manager comes back.
+	g_QueryManagerPool = make(chan *TQueryManagerConnection, g_QueryManagerMaxConnections)
+	for Index := 0; Index < g_QueryManagerMaxConnections; Index += 1 {
+		Connection := &TQueryManagerConnection{}
+		if !Connection.Connect() {
+			g_LogWarn.Print("Failed to connect to query manager, retrying in the background")
+			go ReplaceQueryManagerConnection(Connection)
+			continue
 		}
+		g_QueryManagerPool <- Connection
+	}
 
-		if Current.LastAccess.Before(LeastRecentlyUsedTime) {
-			LeastRecentlyUsedIndex = Index
-			LeastRecentlyUsedTime = Current.LastAccess
-		}
+	g_QueryBackgroundStop = make(chan struct{})
+	if g_QueryManagerKeepaliveInterval > 0 {
+		go QueryManagerKeepalive(g_QueryBackgroundStop)
+	}
+
+	if g_WorldRefresherInterval > 0 {
+		go WorldRefresher(g_QueryBackgroundStop)
+	}
+
+	return true
+}
+
+func ExitQuery() {
+	if g_QueryBackgroundStop != nil {
+		close(g_QueryBackgroundStop)
+		g_QueryBackgroundStop = nil
+	}
+
+	if g_AccountCache != nil {
+		LogCacheStats("AccountCache", g_AccountCache.Stats())
+		LogCacheStats("CharacterCache", g_CharacterCache.Stats())
+		LogCacheStats("WorldCache", g_WorldCache.Stats())
+		LogCacheStats("OnlineCharactersCache", g_OnlineCharactersCache.Stats())
+		LogCacheStats("KillStatisticsCache", g_KillStatisticsCache.Stats())
+		LogCacheStats("HighscoresCache", g_HighscoresCache.Stats())
+		LogCacheStats("GuildsCache", g_GuildsCache.Stats())
+		LogCacheStats("GuildCache", g_GuildCache.Stats())
+		LogCacheStats("HousesCache", g_HousesCache.Stats())
+		LogCacheStats("LatestDeathsCache", g_LatestDeathsCache.Stats())
+	}
+
+	if g_QueryManagerPool == nil {
+		return
+	}
 
-		if Current.AccountID == AccountID {
-			Entry = Current
-			break
+	// NOTE(fusion): Only idle connections are closed here. Any connection that
+	// is still in use will be closed by the OS when the process exits.
+	for {
+		select {
+		case Connection := <-g_QueryManagerPool:
+			Connection.Disconnect()
+		default:
+			return
 		}
 	}
+}
 
-	if Entry == nil {
-		Result, Account = g_QueryManagerConnection.GetAccountSummary(AccountID)
-		if Result == 0 {
-			Entry = &g_AccountCache[LeastRecentlyUsedIndex]
-			Entry.AccountID = AccountID
-			Entry.Data = Account
-			Entry.LastAccess = time.Now()
+func AcquireQueryManagerConnection() *TQueryManagerConnection {
+	// NOTE(fusion): Don't keep request handlers waiting when the query manager
+	// is known to be down. Idle connections are still handed out because they
+	// may have reconnected since, and using one is what clears degraded mode.
+	if IsQueryManagerDegraded() {
+		select {
+		case Connection := <-g_QueryManagerPool:
+			return Connection
+		default:
+			return nil
 		}
+	}
+
+	const AcquireTimeout = 5 * time.Second
+	select {
+	case Connection := <-g_QueryManagerPool:
+		return Connection
+	case <-time.After(AcquireTimeout):
+		g_LogErr.Print("Timed out waiting for a query manager connection")
+		return nil
+	}
+}
+
+func ReleaseQueryManagerConnection(Connection *TQueryManagerConnection) {
+	// NOTE(fusion): `ExecuteQuery` already tries to reconnect inline so if the
+	// connection is still broken at this point, it's likely the query manager
+	// is down and we don't want to keep request handlers waiting on it.
+	if Connection.Handle == nil {
+		go ReplaceQueryManagerConnection(Connection)
 	} else {
-		Result = 0
-		Account = Entry.Data
-		Entry.LastAccess = time.Now()
+		g_QueryManagerPool <- Connection
 	}
+}
 
-	return
+func ReplaceQueryManagerConnection(Connection *TQueryManagerConnection) {
+	const MinRetryInterval = 1 * time.Second
+	const MaxRetryInterval = 1 * time.Minute
+	RetryInterval := MinRetryInterval
+	for !Connection.Connect() {
+		time.Sleep(RetryInterval)
+		RetryInterval = min(RetryInterval*2, MaxRetryInterval)
+	}
+	g_QueryManagerPool <- Connection
 }
 
-func InvalidateAccountCachedData(AccountID int) {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-	for Index := 0; Index < len(g_AccountCache); Index += 1 {
-		if g_AccountCache[Index].AccountID == AccountID {
-			g_AccountCache[Index] = TAccountCacheEntry{}
-			break
+func QueryManagerKeepalive(Stop chan struct{}) {
+	Ticker := time.NewTicker(g_QueryManagerKeepaliveInterval)
+	defer Ticker.Stop()
+	for {
+		select {
+		case <-Stop:
+			return
+		case <-Ticker.C:
+		}
+
+		// NOTE(fusion): Only idle connections are pinged. If there are none,
+		// they're either busy, which already tells us whether the query manager
+		// is alive, or reconnecting, in which case `Connect` will do.
+		select {
+		case Connection := <-g_QueryManagerPool:
+			Connection.Ping()
+			ReleaseQueryManagerConnection(Connection)
+		default:
 		}
 	}
 }
 
-func GetCharacterProfile(CharacterName string) (Result int, Character TCharacterProfile) {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
+func WorldRefresher(Stop chan struct{}) {
+	Ticker := time.NewTicker(g_WorldRefresherInterval)
+	defer Ticker.Stop()
+	for {
+		RefreshWorldData()
+		select {
+		case <-Stop:
+			return
+		case <-Ticker.C:
+		}
+	}
+}
 
-	if g_CharacterCache == nil {
-		g_CharacterCache = make([]TCharacterCacheEntry, g_MaxCachedCharacters)
+// NOTE(fusion): Entries are refreshed when they're due to expire before the
+// refresher runs again, with one interval of slack. Failed refreshes leave
+// the cache untouched so the last good data keeps being served.
+func RefreshWorldData() {
+	if IsQueryManagerDegraded() {
+		return
 	}
 
-	var Entry *TCharacterCacheEntry
-	LeastRecentlyUsedIndex := 0
-	LeastRecentlyUsedTime := g_CharacterCache[0].LastAccess
-	for Index := 0; Index < len(g_CharacterCache); Index += 1 {
-		Current := &g_CharacterCache[Index]
+	Margin := 2 * g_WorldRefresherInterval
+	if g_WorldCache.TimeToLive(struct{}{}) < Margin {
+		FetchCached(g_WorldCache, struct{}{},
+			func(Connection *TQueryManagerConnection) (int, []TWorld) {
+				return Connection.GetWorlds()
+			})
+	}
 
-		if time.Since(Current.LastAccess) >= g_CharacterRefreshInterval {
-			*Current = TCharacterCacheEntry{}
+	Worlds, _ := g_WorldCache.Peek(struct{}{})
+	for _, World := range Worlds.Data {
+		Key := strings.ToLower(World.Name)
+		if g_OnlineCharactersCache.TimeToLive(Key) < Margin {
+			FetchCached(g_OnlineCharactersCache, Key,
+				func(Connection *TQueryManagerConnection) (int, []TOnlineCharacter) {
+					return Connection.GetOnlineCharacters(World.Name)
+				})
 		}
 
-		if Current.LastAccess.Before(LeastRecentlyUsedTime) {
-			LeastRecentlyUsedIndex = Index
-			LeastRecentlyUsedTime = Current.LastAccess
+		if g_KillStatisticsCache.TimeToLive(Key) < Margin {
+			FetchCached(g_KillStatisticsCache, Key,
+				func(Connection *TQueryManagerConnection) (int, []TKillStatistics) {
+					return Connection.GetKillStatistics(World.Name)
+				})
 		}
+	}
+}
 
-		if strings.EqualFold(Current.CharacterName, CharacterName) {
-			Entry = Current
-			break
+func IsQueryManagerDegraded() bool {
+	return g_QueryManagerDegraded.Load()
+}
+
+func SetQueryManagerDegraded(Degraded bool) {
+	if g_QueryManagerDegraded.Swap(Degraded) != Degraded {
+		if Degraded {
+			g_LogWarn.Print("Query manager is unreachable, entering degraded mode")
+		} else {
+			g_Log.Print("Query manager is reachable, leaving degraded mode")
 		}
 	}
+}
 
-	if Entry == nil {
-		Result, Character = g_QueryManagerConnection.GetCharacterProfile(CharacterName)
-		Entry = &g_CharacterCache[LeastRecentlyUsedIndex]
-		Entry.CharacterName = CharacterName
-		Entry.Result = Result
-		Entry.Data = Character
-		Entry.LastAccess = time.Now()
-	} else {
-		Result = Entry.Result
-		Character = Entry.Data
-		Entry.LastAccess = time.Now()
+func CheckAccountPassword(AccountID int, Password, IPAddress string) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
 	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.CheckAccountPassword(AccountID, Password, IPAddress)
+}
 
-	return
+func CreateAccount(AccountID int, Email string, Password string, Activated bool) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.CreateAccount(AccountID, Email, Password, Activated)
 }
 
-func GetWorlds() []TWorld {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-	if time.Until(g_WorldCacheRefreshTime) <= 0 {
-		// IMPORTANT(fusion): `GetWorlds` will return a FRESH slice. This will
-		// prevent race conditions regarding any previous world slice, assuming
-		// we're only reading from them.
-		Result, Worlds := g_QueryManagerConnection.GetWorlds()
-		if Result == 0 {
-			g_WorldCache = Worlds
-			g_WorldCacheRefreshTime = time.Now().Add(g_WorldRefreshInterval)
+func ActivateAccount(AccountID int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.ActivateAccount(AccountID)
+}
+
+func RequestAccountRecovery(Email string, IPAddress string) (int, int, string) {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1, 0, ""
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.RequestAccountRecovery(Email, IPAddress)
+}
+
+func RecoverAccount(Token string, Password string) (int, int) {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1, 0
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.RecoverAccount(Token, Password)
+}
+
+func ChangePassword(AccountID int, Password string) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.ChangePassword(AccountID, Password)
+}
+
+func ChangeEmail(AccountID int, Email string) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.ChangeEmail(AccountID, Email)
+}
+
+func DeleteAccount(AccountID int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.DeleteAccount(AccountID)
+}
+
+func UndeleteAccount(AccountID int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.UndeleteAccount(AccountID)
+}
+
+func CreateCharacter(World string, AccountID int, Name string, Sex int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.CreateCharacter(World, AccountID, Name, Sex)
+}
+
+func DeleteCharacter(AccountID int, CharacterName string) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.DeleteCharacter(AccountID, CharacterName)
+}
+
+func UndeleteCharacter(AccountID int, CharacterName string) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.UndeleteCharacter(AccountID, CharacterName)
+}
+
+func SetCharacterHidden(AccountID int, CharacterName string, Hidden bool) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.SetCharacterHidden(AccountID, CharacterName, Hidden)
+}
+
+// NOTE(fusion): Access tokens are NOT cached so that revoking a token takes
+// effect immediately.
+func CreateAccessToken(AccountID int, Name string, TokenHash string, Scopes int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.CreateAccessToken(AccountID, Name, TokenHash, Scopes)
+}
+
+func GetAccessTokens(AccountID int) (int, []TAccessToken) {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1, nil
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.GetAccessTokens(AccountID)
+}
+
+func RevokeAccessToken(AccountID int, TokenID int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.RevokeAccessToken(AccountID, TokenID)
+}
+
+func RevokeAllAccessTokens(AccountID int) int {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.RevokeAllAccessTokens(AccountID)
+}
+
+func CheckAccessToken(TokenHash string) (int, int, int) {
+	Connection := AcquireQueryManagerConnection()
+	if Connection == nil {
+		return -1, 0, 0
+	}
+	defer ReleaseQueryManagerConnection(Connection)
+	return Connection.CheckAccessToken(TokenHash)
+}
+
+// NOTE(fusion): Negative results are treated as query failures, in which case
+// expired data is served if there is any, and are not cached unless the cache
+// has a TTL policy saying otherwise. Anything else, including "not found"
+// results, is cached along with its data.
+func QueryCached[K comparable, V any](Cache *TCache[K, TCachedResult[V]], Key K,
+	Query func(Connection *TQueryManagerConnection) (int, V)) (int, V) {
+	if Cached, Ok := Cache.Get(Key); Ok {
+		return Cached.Result, Cached.Data
+	}
+
+	Stale, HaveStale := Cache.Peek(Key)
+	if HaveStale && IsQueryManagerDegraded() {
+		return Stale.Result, Stale.Data
+	}
+
+	Fresh := FetchCached(Cache, Key, Query)
+	if Fresh.Result < 0 && HaveStale {
+		return Stale.Result, Stale.Data
+	}
+
+	return Fresh.Result, Fresh.Data
+}
+
+// NOTE(fusion): Same as `QueryCached` except that expired data is served right
+// away and refreshed in the background. The caller only waits on the query
+// manager if there is no data at all.
+func QueryCachedBackground[K comparable, V any](Cache *TCache[K, TCachedResult[V]], Key K,
+	Query func(Connection *TQueryManagerConnection) (int, V)) (int, V) {
+	if Cached, Ok := Cache.Get(Key); Ok {
+		return Cached.Result, Cached.Data
+	}
+
+	if Stale, Ok := Cache.Peek(Key); Ok {
+		go FetchCached(Cache, Key, Query)
+		return Stale.Result, Stale.Data
+	}
+
+	Fresh := FetchCached(Cache, Key, Query)
+	return Fresh.Result, Fresh.Data
+}
+
+// NOTE(fusion): Concurrent fetches for the same key share a single query.
+func FetchCached[K comparable, V any](Cache *TCache[K, TCachedResult[V]], Key K,
+	Query func(Connection *TQueryManagerConnection) (int, V)) TCachedResult[V] {
+	Failed := TCachedResult[V]{Result: -1}
+	Fresh, _ := Cache.Flights.Do(Key, Failed, func() TCachedResult[V] {
+		Connection := AcquireQueryManagerConnection()
+		if Connection == nil {
+			return Failed
+		}
+		Result, Data := Query(Connection)
+		ReleaseQueryManagerConnection(Connection)
+		Fresh := TCachedResult[V]{Result: Result, Data: Data}
+		if Result >= 0 {
+			Cache.Put(Key, Fresh)
+		} else if Cache.TTLFunc != nil {
+			// NOTE(fusion): Failures may be cached briefly if the cache's TTL
+			// policy allows it, but never over data that could still be served
+			// as stale.
+			if _, Ok := Cache.Peek(Key); !Ok {
+				Cache.Put(Key, Fresh)
+			}
 		}
+		return Fresh
+	})
+	return Fresh
+}
+
+func GetAccountSummary(AccountID int) (Result int, Account TAccountSummary) {
+	// NOTE(fusion): Account data itself shouldn't change over time unless we do
+	// it ourselves, in which case `InvalidateAccountCachedData` is used to remove
+	// the cache entry. The problem is that the account summary also includes
+	// character data which will change, depending on activities on the game
+	// server, hence the TTL.
+	return QueryCached(g_AccountCache, AccountID,
+		func(Connection *TQueryManagerConnection) (int, TAccountSummary) {
+			return Connection.GetAccountSummary(AccountID)
+		})
+}
+
+func InvalidateAccountCachedData(AccountID int) {
+	g_AccountCache.Remove(AccountID)
+}
+
+func GetCharacterProfile(CharacterName string) (Result int, Character TCharacterProfile) {
+	return QueryCached(g_CharacterCache, strings.ToLower(CharacterName),
+		func(Connection *TQueryManagerConnection) (int, TCharacterProfile) {
+			Result, Character := Connection.GetCharacterProfile(CharacterName)
+			if Result == 0 {
+				// NOTE(fusion): Deaths are cached along with the profile because
+				// they're always displayed together. If they fail to load, the
+				// profile is still shown but only cached for the error TTL so the
+				// deaths are fetched again soon instead of looking like there are
+				// none for the full TTL.
+				const MaxCharacterDeaths = 20
+				DeathsResult, Deaths := Connection.GetCharacterDeaths(
+					Character.Name, MaxCharacterDeaths)
+				if DeathsResult == 0 {
+					Character.Deaths = Deaths
+				} else {
+					g_LogErr.Printf("Failed to load deaths of \"%v\" (%v)",
+						Character.Name, DeathsResult)
+					Character.DeathsFailed = true
+				}
+			}
+			return Result, Character
+		})
+}
+
+// NOTE(fusion): Names that don't exist are cached for less time so that newly
+// created characters show up quickly, and errors are usually not cached at all
+// so that a single query manager hiccup doesn't stick around. Profiles whose
+// deaths failed to load count as errors.
+func CharacterCacheTTL(Cached TCachedResult[TCharacterProfile]) time.Duration {
+	switch {
+	case Cached.Result == 0 && Cached.Data.DeathsFailed:
+		return g_CharacterErrorCacheTTL
+	case Cached.Result == 0:
+		return g_CharacterCacheTTL
+	case Cached.Result > 0:
+		return g_CharacterNotFoundCacheTTL
+	default:
+		return g_CharacterErrorCacheTTL
 	}
-	return g_WorldCache
+}
+
+func InvalidateCharacterCachedData(CharacterName string) {
+	g_CharacterCache.Remove(strings.ToLower(CharacterName))
+}
+
+func GetWorlds() []TWorld {
+	// IMPORTANT(fusion): `GetWorlds` will return a FRESH slice. This will
+	// prevent race conditions regarding any previous world slice, assuming
+	// we're only reading from them.
+	_, Worlds := QueryCachedBackground(g_WorldCache, struct{}{},
+		func(Connection *TQueryManagerConnection) (int, []TWorld) {
+			return Connection.GetWorlds()
+		})
+	return Worlds
+}
+
+func GetWorldsUpdated() time.Time {
+	return g_WorldCache.Updated(struct{}{})
 }
 
 func GetWorld(World string) *TWorld {
@@ -669,75 +1964,79 @@ func GetWorld(World string) *TWorld {
 }
 
 func GetOnlineCharacters(World string) []TOnlineCharacter {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-
-	var Entry *TOnlineCharactersCacheEntry
-	for Index := 0; Index < len(g_OnlineCharactersCache); Index += 1 {
-		Current := &g_OnlineCharactersCache[Index]
-		if time.Until(Current.RefreshTime) <= 0 {
-			g_OnlineCharactersCache = SwapAndPop(g_OnlineCharactersCache, Index)
-			Index -= 1
-			continue
-		}
+	_, Characters := QueryCachedBackground(g_OnlineCharactersCache, strings.ToLower(World),
+		func(Connection *TQueryManagerConnection) (int, []TOnlineCharacter) {
+			return Connection.GetOnlineCharacters(World)
+		})
+	return Characters
+}
 
-		if strings.EqualFold(Current.World, World) {
-			Entry = Current
-			break
-		}
-	}
+func GetOnlineCharactersUpdated(World string) time.Time {
+	return g_OnlineCharactersCache.Updated(strings.ToLower(World))
+}
 
-	if Entry == nil {
-		Result, Characters := g_QueryManagerConnection.GetOnlineCharacters(World)
-		if Result == 0 {
-			g_OnlineCharactersCache = append(g_OnlineCharactersCache, TOnlineCharactersCacheEntry{})
-			Entry = &g_OnlineCharactersCache[len(g_OnlineCharactersCache)-1]
-			Entry.World = World
-			Entry.Data = Characters
-			Entry.RefreshTime = time.Now().Add(g_WorldRefreshInterval)
-		}
-	}
+func GetKillStatistics(World string) []TKillStatistics {
+	_, Stats := QueryCachedBackground(g_KillStatisticsCache, strings.ToLower(World),
+		func(Connection *TQueryManagerConnection) (int, []TKillStatistics) {
+			return Connection.GetKillStatistics(World)
+		})
+	return Stats
+}
 
-	if Entry != nil {
-		return Entry.Data
-	} else {
-		return nil
-	}
+func GetKillStatisticsUpdated(World string) time.Time {
+	return g_KillStatisticsCache.Updated(strings.ToLower(World))
 }
 
-func GetKillStatistics(World string) []TKillStatistics {
-	g_QueryManagerMutex.Lock()
-	defer g_QueryManagerMutex.Unlock()
-
-	var Entry *TKillStatisticsCacheEntry
-	for Index := 0; Index < len(g_KillStatisticsCache); Index += 1 {
-		Current := &g_KillStatisticsCache[Index]
-		if time.Until(Current.RefreshTime) <= 0 {
-			g_KillStatisticsCache = SwapAndPop(g_KillStatisticsCache, Index)
-			Index -= 1
-			continue
-		}
+func GetHighscores(World string, Category int) []THighscoreEntry {
+	// NOTE(fusion): Highscores are paged by the website so we always request
+	// the maximum amount of entries and cache them all at once.
+	const MaxHighscoreEntries = 300
+	Key := THighscoresCacheKey{World: strings.ToLower(World), Category: Category}
+	_, Entries := QueryCachedBackground(g_HighscoresCache, Key,
+		func(Connection *TQueryManagerConnection) (int, []THighscoreEntry) {
+			return Connection.GetHighscores(World, Category, MaxHighscoreEntries)
+		})
+	return Entries
+}
 
-		if strings.EqualFold(Current.World, World) {
-			Entry = Current
-			break
-		}
-	}
+func GetGuilds(World string) []TGuildSummary {
+	_, Guilds := QueryCachedBackground(g_GuildsCache, strings.ToLower(World),
+		func(Connection *TQueryManagerConnection) (int, []TGuildSummary) {
+			return Connection.GetGuilds(World)
+		})
+	return Guilds
+}
+
+func GetGuild(GuildName string) (Result int, Guild TGuild) {
+	return QueryCached(g_GuildCache, strings.ToLower(GuildName),
+		func(Connection *TQueryManagerConnection) (int, TGuild) {
+			return Connection.GetGuild(GuildName)
+		})
+}
+
+func GetHouses(World string) []THouse {
+	_, Houses := QueryCachedBackground(g_HousesCache, strings.ToLower(World),
+		func(Connection *TQueryManagerConnection) (int, []THouse) {
+			return Connection.GetHouses(World)
+		})
+	return Houses
+}
 
-	if Entry == nil {
-		Result, Stats := g_QueryManagerConnection.GetKillStatistics(World)
-		if Result == 0 {
-			g_KillStatisticsCache = append(g_KillStatisticsCache, TKillStatisticsCacheEntry{})
-			Entry = &g_KillStatisticsCache[len(g_KillStatisticsCache)-1]
-			Entry.World = World
-			Entry.Data = Stats
-			Entry.RefreshTime = time.Now().Add(g_WorldRefreshInterval)
+func GetHouse(World string, HouseID int) *THouse {
+	Houses := GetHouses(World)
+	for Index := range Houses {
+		if Houses[Index].HouseID == HouseID {
+			return &Houses[Index]
 		}
 	}
+	return nil
+}
 
-	if Entry != nil {
-		return Entry.Data
-	} else {
-		return nil
-	}
+func GetLatestDeaths(World string) []TWorldDeath {
+	const MaxLatestDeaths = 100
+	_, Deaths := QueryCachedBackground(g_LatestDeathsCache, strings.ToLower(World),
+		func(Connection *TQueryManagerConnection) (int, []TWorldDeath) {
+			return Connection.GetLatestDeaths(World, MaxLatestDeaths)
+		})
+	return Deaths
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate Limiting
+// ==============================================================================
+// NOTE(fusion): Token buckets keyed by IP address or account number. Each bucket
+// holds up to `Burst` tokens and regains one every `Interval`, with every attempt
+// taking a token whether it succeeds or not. This is meant to keep anyone from
+// flooding the query manager through us, which still has its own lockouts for
+// failed logins.
+type (
+	TRateLimiter struct {
+		Burst    int
+		Interval time.Duration
+		Mutex    sync.Mutex
+		Buckets  map[string]TRateBucket
+	}
+
+	TRateBucket struct {
+		Tokens  float64
+		Updated time.Time
+	}
+)
+
+var (
+	g_LoginIPLimiter          *TRateLimiter
+	g_LoginAccountLimiter     *TRateLimiter
+	g_AccountCreateIPLimiter  *TRateLimiter
+	g_AccountRecoverIPLimiter *TRateLimiter
+	g_ApiTokenIPLimiter       *TRateLimiter
+	g_RateLimitSweeperStop    chan struct{}
+)
+
+func NewRateLimiter(Name string, Burst int, Interval time.Duration) *TRateLimiter {
+	g_Log.Printf("%v: %v attempts, one more every %v", Name, Burst, Interval)
+	if Burst <= 0 || Interval <= 0 {
+		g_LogWarn.Printf("%v is disabled", Name)
+	}
+
+	return &TRateLimiter{
+		Burst:    Burst,
+		Interval: Interval,
+		Buckets:  make(map[string]TRateBucket),
+	}
+}
+
+func (Limiter *TRateLimiter) Enabled() bool {
+	return Limiter != nil && Limiter.Burst > 0 && Limiter.Interval > 0
+}
+
+// NOTE(fusion): Takes a token from `Key`'s bucket, returning zero if there was
+// one available or how long until there is one otherwise.
+func (Limiter *TRateLimiter) Take(Key string) time.Duration {
+	if !Limiter.Enabled() {
+		return 0
+	}
+
+	Now := time.Now()
+	Limiter.Mutex.Lock()
+	defer Limiter.Mutex.Unlock()
+
+	Bucket, Ok := Limiter.Buckets[Key]
+	if !Ok {
+		Bucket.Tokens = float64(Limiter.Burst)
+	} else {
+		Refill := float64(Now.Sub(Bucket.Updated)) / float64(Limiter.Interval)
+		Bucket.Tokens = min(Bucket.Tokens+Refill, float64(Limiter.Burst))
+	}
+	Bucket.Updated = Now
+
+	var Wait time.Duration
+	if Bucket.Tokens >= 1 {
+		Bucket.Tokens -= 1
+	} else {
+		Wait = time.Duration((1 - Bucket.Tokens) * float64(Limiter.Interval))
+	}
+
+	Limiter.Buckets[Key] = Bucket
+	return Wait
+}
+
+// NOTE(fusion): Buckets that would be full by now are the same as no bucket at
+// all, so they're removed to keep memory bounded.
+func (Limiter *TRateLimiter) Sweep() {
+	if !Limiter.Enabled() {
+		return
+	}
+
+	Now := time.Now()
+	FullAfter := time.Duration(Limiter.Burst) * Limiter.Interval
+	Limiter.Mutex.Lock()
+	defer Limiter.Mutex.Unlock()
+	for Key, Bucket := range Limiter.Buckets {
+		if Now.Sub(Bucket.Updated) >= FullAfter {
+			delete(Limiter.Buckets, Key)
+		}
+	}
+}
+
+func RateLimitSweeper(Stop chan struct{}) {
+	Ticker := time.NewTicker(time.Minute)
+	defer Ticker.Stop()
+	for {
+		select {
+		case <-Stop:
+			return
+		case <-Ticker.C:
+		}
+
+		g_LoginIPLimiter.Sweep()
+		g_LoginAccountLimiter.Sweep()
+		g_AccountCreateIPLimiter.Sweep()
+		g_AccountRecoverIPLimiter.Sweep()
+		g_ApiTokenIPLimiter.Sweep()
+	}
+}
+
+func InitRateLimits() bool {
+	g_LoginIPLimiter = NewRateLimiter("LoginLimitPerIP",
+		g_LoginBurstPerIP, g_LoginIntervalPerIP)
+	g_LoginAccountLimiter = NewRateLimiter("LoginLimitPerAccount",
+		g_LoginBurstPerAccount, g_LoginIntervalPerAccount)
+	g_AccountCreateIPLimiter = NewRateLimiter("AccountCreateLimitPerIP",
+		g_AccountCreateBurstPerIP, g_AccountCreateIntervalPerIP)
+	g_AccountRecoverIPLimiter = NewRateLimiter("AccountRecoverLimitPerIP",
+		g_AccountRecoverBurstPerIP, g_AccountRecoverIntervalPerIP)
+	g_ApiTokenIPLimiter = NewRateLimiter("ApiTokenLimitPerIP",
+		g_ApiTokenBurstPerIP, g_ApiTokenIntervalPerIP)
+
+	g_RateLimitSweeperStop = make(chan struct{})
+	go RateLimitSweeper(g_RateLimitSweeperStop)
+	return true
+}
+
+func ExitRateLimits() {
+	if g_RateLimitSweeperStop != nil {
+		close(g_RateLimitSweeperStop)
+		g_RateLimitSweeperStop = nil
+	}
+}
+
+// NOTE(fusion): Returns whether the request may go through. Otherwise the error
+// page is rendered here so handlers only need to bail out.
+func (Limiter *TRateLimiter) Allow(Context *THttpRequestContext, Key string) bool {
+	if Wait := Limiter.Take(Key); Wait > 0 {
+		TooManyRequests(Context, Wait)
+		return false
+	}
+	return true
+}
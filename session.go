@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"net/http"
 	"sync"
@@ -14,6 +15,7 @@ type TSession struct {
 	IPAddress string
 	Expires   time.Time
 	AccountID int
+	CSRFToken string
 }
 
 // IMPORTANT(fusion): Ideally you'd save sessions in a database to reduce memory
@@ -58,8 +60,9 @@ func GetRequestSessionID(Request *http.Request) []byte {
 	return SessionID
 }
 
-func SessionLookup(SessionID []byte, IPAddress string) int {
+func SessionLookup(SessionID []byte, IPAddress string) (int, string) {
 	AccountID := 0
+	CSRFToken := ""
 	if SessionID != nil && IPAddress != "" {
 		g_SessionsMutex.Lock()
 		defer g_SessionsMutex.Unlock()
@@ -74,11 +77,12 @@ func SessionLookup(SessionID []byte, IPAddress string) int {
 
 			if bytes.Equal(Session.SessionID, SessionID) && Session.IPAddress == IPAddress {
 				AccountID = Session.AccountID
+				CSRFToken = Session.CSRFToken
 				break
 			}
 		}
 	}
-	return AccountID
+	return AccountID, CSRFToken
 }
 
 func SessionStart(Context *THttpRequestContext, AccountID int) {
@@ -93,8 +97,14 @@ func SessionStart(Context *THttpRequestContext, AccountID int) {
 		return
 	}
 
+	CSRFToken := GenerateCSRFToken()
+	if CSRFToken == "" {
+		return
+	}
+
 	Context.SessionID = SessionID
 	Context.AccountID = AccountID
+	Context.CSRFToken = CSRFToken
 	Expires := time.Now().Add(time.Hour)
 	http.SetCookie(Context.Writer, &http.Cookie{
 		Name:     "GOSESSID",
@@ -113,6 +123,7 @@ func SessionStart(Context *THttpRequestContext, AccountID int) {
 			IPAddress: Context.IPAddress,
 			Expires:   Expires,
 			AccountID: AccountID,
+			CSRFToken: CSRFToken,
 		})
 }
 
@@ -128,11 +139,16 @@ func SessionEnd(Context *THttpRequestContext) {
 		Expires: time.Unix(0, 0),
 	})
 
+	SessionID := Context.SessionID
+	Context.SessionID = nil
+	Context.AccountID = 0
+	Context.CSRFToken = AnonymousCSRFToken(Context.IPAddress)
+
 	g_SessionsMutex.Lock()
 	defer g_SessionsMutex.Unlock()
 	for Index := 0; Index < len(g_Sessions); Index += 1 {
 		Session := &g_Sessions[Index]
-		if bytes.Equal(Session.SessionID, Context.SessionID) && Session.IPAddress == Context.IPAddress {
+		if bytes.Equal(Session.SessionID, SessionID) && Session.IPAddress == Context.IPAddress {
 			g_Sessions[Index] = g_Sessions[len(g_Sessions)-1]
 			g_Sessions[len(g_Sessions)-1] = TSession{}
 			g_Sessions = g_Sessions[:len(g_Sessions)-1]
@@ -140,3 +156,61 @@ func SessionEnd(Context *THttpRequestContext) {
 		}
 	}
 }
+
+func SessionEndAccount(AccountID int, KeepSessionID []byte) {
+	if AccountID <= 0 {
+		return
+	}
+
+	// NOTE(fusion): This is used to log out every session of an account after
+	// sensitive changes like a password change. `KeepSessionID` may be used to
+	// keep the session that originated the change alive.
+	g_SessionsMutex.Lock()
+	defer g_SessionsMutex.Unlock()
+	for Index := 0; Index < len(g_Sessions); Index += 1 {
+		Session := &g_Sessions[Index]
+		if Session.AccountID == AccountID && !bytes.Equal(Session.SessionID, KeepSessionID) {
+			g_Sessions = SwapAndPop(g_Sessions, Index)
+			Index -= 1
+		}
+	}
+}
+
+// CSRF Protection
+// ==============================================================================
+// NOTE(fusion): Every form that is POSTed back to us must carry a CSRF token so
+// other websites can't submit forms on behalf of our users. Sessions have their
+// own random token which is stored with them, while anonymous forms (e.g. login
+// or account creation) get a signed token bound to the client's IP address,
+// since there is no session to store it with.
+const (
+	AnonymousCSRFTokenLifetime = 2 * time.Hour
+)
+
+func GenerateCSRFToken() string {
+	var CSRFToken [32]byte
+	if _, Err := rand.Read(CSRFToken[:]); Err != nil {
+		g_LogErr.Printf("Failed to generate CSRF token: %v", Err)
+		return ""
+	}
+	return hex.EncodeToString(CSRFToken[:])
+}
+
+func AnonymousCSRFToken(IPAddress string) string {
+	return SignToken("csrf", time.Now().Add(AnonymousCSRFTokenLifetime), IPAddress)
+}
+
+func CheckCSRFToken(Context *THttpRequestContext, CSRFToken string) bool {
+	if CSRFToken == "" {
+		return false
+	}
+
+	// IMPORTANT(fusion): Signed tokens are NOT accepted for logged in users or
+	// anyone sharing their IP address could forge requests on their behalf.
+	if Context.AccountID > 0 {
+		return subtle.ConstantTimeCompare([]byte(CSRFToken), []byte(Context.CSRFToken)) == 1
+	}
+
+	Values, Ok := VerifyToken("csrf", CSRFToken)
+	return Ok && len(Values) == 1 && Values[0] == Context.IPAddress
+}
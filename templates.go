@@ -4,23 +4,64 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type (
 	CommonTmplData struct {
 		Title     string
 		AccountID int
+		CSRFToken string
+		Degraded  bool
 	}
 
 	GenericTmplData struct {
 		Common CommonTmplData
 	}
 
+	AccountRecoverTmplData struct {
+		Common CommonTmplData
+		Token  string
+	}
+
+	AccountRecoverMailTmplData struct {
+		AccountID int
+		Link      string
+	}
+
+	AccountActivateMailTmplData struct {
+		AccountID int
+		Link      string
+	}
+
+	AccountEmailMailTmplData struct {
+		AccountID int
+		Email     string
+		Link      string
+	}
+
 	AccountTmplData struct {
-		Common  CommonTmplData
-		Account *TAccountSummary
+		Common       CommonTmplData
+		Account      *TAccountSummary
+		AccessTokens []TAccessToken
+	}
+
+	AccountTokenTmplData struct {
+		Common CommonTmplData
+		Name   string
+		Token  string
+	}
+
+	CharacterDeleteTmplData struct {
+		Common   CommonTmplData
+		Name     string
+		Undelete bool
 	}
 
 	CharacterTmplData struct {
@@ -28,21 +69,73 @@ type (
 		Character *TCharacterProfile
 	}
 
+	GuildListTmplData struct {
+		Common CommonTmplData
+		World  *TWorld
+		Guilds []TGuildSummary
+	}
+
+	GuildTmplData struct {
+		Common CommonTmplData
+		Guild  *TGuild
+	}
+
+	HighscoreCategoryTmplData struct {
+		Key      string
+		Name     string
+		Selected bool
+	}
+
+	HighscoresTmplData struct {
+		Common       CommonTmplData
+		World        *TWorld
+		Category     HighscoreCategoryTmplData
+		Categories   []HighscoreCategoryTmplData
+		Entries      []THighscoreEntry
+		Page         int
+		NumPages     int
+		PreviousPage int
+		NextPage     int
+	}
+
+	HouseListTmplData struct {
+		Common CommonTmplData
+		World  *TWorld
+		Town   string
+		Towns  []string
+		Houses []THouse
+	}
+
+	HouseTmplData struct {
+		Common CommonTmplData
+		World  *TWorld
+		House  *THouse
+	}
+
 	KillStatisticsTmplData struct {
 		Common         CommonTmplData
 		World          *TWorld
 		KillStatistics []TKillStatistics
+		Updated        time.Time
+	}
+
+	LatestDeathsTmplData struct {
+		Common CommonTmplData
+		World  *TWorld
+		Deaths []TWorldDeath
 	}
 
 	WorldTmplData struct {
 		Common           CommonTmplData
 		World            *TWorld
 		OnlineCharacters []TOnlineCharacter
+		Updated          time.Time
 	}
 
 	WorldListTmplData struct {
-		Common CommonTmplData
-		Worlds []TWorld
+		Common  CommonTmplData
+		Worlds  []TWorld
+		Updated time.Time
 	}
 
 	MessageTmplData struct {
@@ -57,14 +150,22 @@ var (
 )
 
 func InitTemplates() bool {
+	return LoadTemplates(os.DirFS("templates"))
+}
+
+// NOTE(fusion): The server reads templates from the working directory so they
+// may be changed without rebuilding it, but tests load them from any `fs.FS`.
+func LoadTemplates(Files fs.FS) bool {
 	var Err error
 
 	CustomFuncs := template.FuncMap{
-		"FormatTimestamp": FormatTimestamp,
-		"FormatDurationSince": FormatDurationSince,
+		"FormatTimestamp":         FormatTimestamp,
+		"FormatDurationSince":     FormatDurationSince,
+		"FormatTimeSince":         FormatTimeSince,
+		"AccessTokenScopesString": AccessTokenScopesString,
 	}
 
-	g_Templates, Err = template.New("").Funcs(CustomFuncs).ParseGlob("templates/*.tmpl")
+	g_Templates, Err = template.New("").Funcs(CustomFuncs).ParseFS(Files, "*.tmpl")
 	if Err != nil {
 		g_LogErr.Printf("Failed to parse templates: %v", Err)
 		return false
@@ -83,13 +184,26 @@ func ExecuteTemplate(Writer io.Writer, FileName string, Data any) {
 	}
 }
 
+func RenderMail(FileName string, Data any) (string, bool) {
+	Body := strings.Builder{}
+	Err := g_Templates.ExecuteTemplate(&Body, FileName, Data)
+	if Err != nil {
+		g_LogErr.Printf("Failed to execute mail template \"%v\": %v", FileName, Err)
+		return "", false
+	}
+	return Body.String(), true
+}
+
 func RenderRequestError(Context *THttpRequestContext, Status int) {
 	StatusText := http.StatusText(Status)
+	Context.Writer.WriteHeader(Status)
 	ExecuteTemplate(Context.Writer, "message.tmpl",
 		MessageTmplData{
 			Common: CommonTmplData{
 				Title:     StatusText,
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
 			Heading: strconv.Itoa(Status),
 			Message: StatusText,
@@ -102,6 +216,8 @@ func RenderMessage(Context *THttpRequestContext, Heading string, Message string)
 			Common: CommonTmplData{
 				Title:     Heading,
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
 			Heading: Heading,
 			Message: Message,
@@ -113,24 +229,82 @@ func RenderAccountSummary(Context *THttpRequestContext) {
 		Common: CommonTmplData{
 			Title:     "Account Summary",
 			AccountID: Context.AccountID,
+			CSRFToken: Context.CSRFToken,
+			Degraded:  Context.Backend.IsDegraded(),
 		},
 		Account: nil,
 	}
 
-	Result, Account := GetAccountSummary(Context.AccountID)
+	Result, Account := Context.Backend.GetAccountSummary(Context.AccountID)
 	if Result == 0 {
 		Data.Account = &Account
 	}
 
+	if Result, Tokens := Context.Backend.GetAccessTokens(Context.AccountID); Result == 0 {
+		Data.AccessTokens = Tokens
+	}
+
 	ExecuteTemplate(Context.Writer, "account_summary.tmpl", Data)
 }
 
+func RenderAccountTokenCreated(Context *THttpRequestContext, Name string, Token string) {
+	ExecuteTemplate(Context.Writer, "account_token.tmpl",
+		AccountTokenTmplData{
+			Common: CommonTmplData{
+				Title:     "Access Token Created",
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			Name:  Name,
+			Token: Token,
+		})
+}
+
 func RenderAccountLogin(Context *THttpRequestContext) {
 	ExecuteTemplate(Context.Writer, "account_login.tmpl",
 		GenericTmplData{
 			Common: CommonTmplData{
 				Title:     "Login",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+		})
+}
+
+func RenderAccountPassword(Context *THttpRequestContext) {
+	ExecuteTemplate(Context.Writer, "account_password.tmpl",
+		GenericTmplData{
+			Common: CommonTmplData{
+				Title:     "Change Password",
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+		})
+}
+
+func RenderAccountDelete(Context *THttpRequestContext) {
+	ExecuteTemplate(Context.Writer, "account_delete.tmpl",
+		GenericTmplData{
+			Common: CommonTmplData{
+				Title:     "Delete Account",
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+		})
+}
+
+func RenderAccountEmail(Context *THttpRequestContext) {
+	ExecuteTemplate(Context.Writer, "account_email.tmpl",
+		GenericTmplData{
+			Common: CommonTmplData{
+				Title:     "Change Email",
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
 		})
 }
@@ -141,6 +315,8 @@ func RenderAccountCreate(Context *THttpRequestContext) {
 			Common: CommonTmplData{
 				Title:     "Create Account",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
 		})
 }
@@ -151,7 +327,22 @@ func RenderAccountRecover(Context *THttpRequestContext) {
 			Common: CommonTmplData{
 				Title:     "Recover Account",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+		})
+}
+
+func RenderAccountRecoverConfirm(Context *THttpRequestContext, Token string) {
+	ExecuteTemplate(Context.Writer, "account_recover_confirm.tmpl",
+		AccountRecoverTmplData{
+			Common: CommonTmplData{
+				Title:     "Recover Account",
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
+			Token: Token,
 		})
 }
 
@@ -161,8 +352,29 @@ func RenderCharacterCreate(Context *THttpRequestContext) {
 			Common: CommonTmplData{
 				Title:     "Create Character",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			Worlds: Context.Backend.GetWorlds(),
+		})
+}
+
+func RenderCharacterDelete(Context *THttpRequestContext, CharacterName string, Undelete bool) {
+	Title := "Delete Character"
+	if Undelete {
+		Title = "Undelete Character"
+	}
+
+	ExecuteTemplate(Context.Writer, "character_delete.tmpl",
+		CharacterDeleteTmplData{
+			Common: CommonTmplData{
+				Title:     Title,
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
-			Worlds: GetWorlds(),
+			Name:     CharacterName,
+			Undelete: Undelete,
 		})
 }
 
@@ -177,19 +389,138 @@ func RenderCharacterProfile(Context *THttpRequestContext, Character *TCharacterP
 			Common: CommonTmplData{
 				Title:     Title,
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
 			Character: Character,
 		})
 }
 
+func RenderGuildList(Context *THttpRequestContext, WorldName string) {
+	ExecuteTemplate(Context.Writer, "guild_list.tmpl",
+		GuildListTmplData{
+			Common: CommonTmplData{
+				Title:     fmt.Sprintf("Guilds - %v", WorldName),
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			World:  Context.Backend.GetWorld(WorldName),
+			Guilds: Context.Backend.GetGuilds(WorldName),
+		})
+}
+
+func RenderGuildInfo(Context *THttpRequestContext, Guild *TGuild) {
+	ExecuteTemplate(Context.Writer, "guild_info.tmpl",
+		GuildTmplData{
+			Common: CommonTmplData{
+				Title:     Guild.Name,
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			Guild: Guild,
+		})
+}
+
+func RenderHighscores(Context *THttpRequestContext, WorldName string, Category int, Page int) {
+	const EntriesPerPage = 50
+
+	Data := HighscoresTmplData{
+		Common: CommonTmplData{
+			Title:     fmt.Sprintf("Highscores - %v", WorldName),
+			AccountID: Context.AccountID,
+			CSRFToken: Context.CSRFToken,
+			Degraded:  Context.Backend.IsDegraded(),
+		},
+		World: Context.Backend.GetWorld(WorldName),
+		Category: HighscoreCategoryTmplData{
+			Key:      HighscoreCategoryKey(Category),
+			Name:     HighscoreCategoryString(Category),
+			Selected: true,
+		},
+	}
+
+	for Current := HIGHSCORE_LEVEL; Current <= HIGHSCORE_FISHING; Current += 1 {
+		Data.Categories = append(Data.Categories,
+			HighscoreCategoryTmplData{
+				Key:      HighscoreCategoryKey(Current),
+				Name:     HighscoreCategoryString(Current),
+				Selected: Current == Category,
+			})
+	}
+
+	Entries := Context.Backend.GetHighscores(WorldName, Category)
+	Data.NumPages = max(1, (len(Entries)+EntriesPerPage-1)/EntriesPerPage)
+	Data.Page = min(Page, Data.NumPages)
+	if Data.Page > 1 {
+		Data.PreviousPage = Data.Page - 1
+	}
+	if Data.Page < Data.NumPages {
+		Data.NextPage = Data.Page + 1
+	}
+
+	Start := (Data.Page - 1) * EntriesPerPage
+	End := min(Start+EntriesPerPage, len(Entries))
+	if Start < End {
+		Data.Entries = Entries[Start:End]
+	}
+
+	ExecuteTemplate(Context.Writer, "highscores.tmpl", Data)
+}
+
+func RenderHouseList(Context *THttpRequestContext, WorldName string, Town string) {
+	Data := HouseListTmplData{
+		Common: CommonTmplData{
+			Title:     fmt.Sprintf("Houses - %v", WorldName),
+			AccountID: Context.AccountID,
+			CSRFToken: Context.CSRFToken,
+			Degraded:  Context.Backend.IsDegraded(),
+		},
+		World: Context.Backend.GetWorld(WorldName),
+	}
+
+	for _, House := range Context.Backend.GetHouses(WorldName) {
+		if !slices.Contains(Data.Towns, House.Town) {
+			Data.Towns = append(Data.Towns, House.Town)
+		}
+
+		if Town == "" || strings.EqualFold(House.Town, Town) {
+			Data.Houses = append(Data.Houses, House)
+		}
+	}
+
+	if Town != "" && len(Data.Houses) > 0 {
+		Data.Town = Data.Houses[0].Town
+	}
+
+	ExecuteTemplate(Context.Writer, "house_list.tmpl", Data)
+}
+
+func RenderHouseInfo(Context *THttpRequestContext, WorldName string, House *THouse) {
+	ExecuteTemplate(Context.Writer, "house_info.tmpl",
+		HouseTmplData{
+			Common: CommonTmplData{
+				Title:     House.Name,
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			World: Context.Backend.GetWorld(WorldName),
+			House: House,
+		})
+}
+
 func RenderKillStatisticsList(Context *THttpRequestContext) {
 	ExecuteTemplate(Context.Writer, "killstatistics_list.tmpl",
 		WorldListTmplData{
 			Common: CommonTmplData{
 				Title:     "Kill Statistics",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
-			Worlds: GetWorlds(),
+			Worlds: Context.Backend.GetWorlds(),
 		})
 }
 
@@ -199,9 +530,26 @@ func RenderKillStatistics(Context *THttpRequestContext, WorldName string) {
 			Common: CommonTmplData{
 				Title:     fmt.Sprintf("Kill Statistics - %v", WorldName),
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
+			},
+			World:          Context.Backend.GetWorld(WorldName),
+			KillStatistics: Context.Backend.GetKillStatistics(WorldName),
+			Updated:        Context.Backend.GetKillStatisticsUpdated(WorldName),
+		})
+}
+
+func RenderLatestDeaths(Context *THttpRequestContext, WorldName string) {
+	ExecuteTemplate(Context.Writer, "latest_deaths.tmpl",
+		LatestDeathsTmplData{
+			Common: CommonTmplData{
+				Title:     fmt.Sprintf("Latest Deaths - %v", WorldName),
+				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
-			World:          GetWorld(WorldName),
-			KillStatistics: GetKillStatistics(WorldName),
+			World:  Context.Backend.GetWorld(WorldName),
+			Deaths: Context.Backend.GetLatestDeaths(WorldName),
 		})
 }
 
@@ -211,8 +559,11 @@ func RenderWorldList(Context *THttpRequestContext) {
 			Common: CommonTmplData{
 				Title:     "Worlds",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
-			Worlds: GetWorlds(),
+			Worlds:  Context.Backend.GetWorlds(),
+			Updated: Context.Backend.GetWorldsUpdated(),
 		})
 }
 
@@ -222,8 +573,11 @@ func RenderWorldInfo(Context *THttpRequestContext, WorldName string) {
 			Common: CommonTmplData{
 				Title:     "Worlds",
 				AccountID: Context.AccountID,
+				CSRFToken: Context.CSRFToken,
+				Degraded:  Context.Backend.IsDegraded(),
 			},
-			World:            GetWorld(WorldName),
-			OnlineCharacters: GetOnlineCharacters(WorldName),
+			World:            Context.Backend.GetWorld(WorldName),
+			OnlineCharacters: Context.Backend.GetOnlineCharacters(WorldName),
+			Updated:          Context.Backend.GetOnlineCharactersUpdated(WorldName),
 		})
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// IMPORTANT(fusion): Signed tokens are used for links sent through email and
+// other short lived data that we don't want to store server side. The payload
+// is NOT encrypted, only signed, so it must not contain anything secret.
+
+type TTokenPayload struct {
+	Purpose string
+	Expires int64
+	Values  []string
+}
+
+var (
+	g_TokenKey []byte
+)
+
+func InitTokens() bool {
+	if g_TokenSecret != "" {
+		g_TokenKey = []byte(g_TokenSecret)
+	} else {
+		g_LogWarn.Print("TokenSecret is not set. A random key will be used which" +
+			" means any links sent through email will stop working when the server" +
+			" is restarted.")
+		g_TokenKey = make([]byte, 32)
+		if _, Err := rand.Read(g_TokenKey); Err != nil {
+			g_LogErr.Printf("Failed to generate token key: %v", Err)
+			return false
+		}
+	}
+	return true
+}
+
+func ExitTokens() {
+	g_TokenKey = nil
+}
+
+func ComputeTokenSignature(Payload []byte) []byte {
+	Mac := hmac.New(sha256.New, g_TokenKey)
+	Mac.Write(Payload)
+	return Mac.Sum(nil)
+}
+
+func SignToken(Purpose string, Expires time.Time, Values ...string) string {
+	Payload, Err := json.Marshal(TTokenPayload{
+		Purpose: Purpose,
+		Expires: Expires.Unix(),
+		Values:  Values,
+	})
+	if Err != nil {
+		g_LogErr.Printf("Failed to encode token payload: %v", Err)
+		return ""
+	}
+
+	Signature := ComputeTokenSignature(Payload)
+	return base64.RawURLEncoding.EncodeToString(Payload) + "." +
+		base64.RawURLEncoding.EncodeToString(Signature)
+}
+
+func VerifyToken(Purpose string, Token string) ([]string, bool) {
+	EncodedPayload, EncodedSignature, Ok := strings.Cut(Token, ".")
+	if !Ok {
+		return nil, false
+	}
+
+	Payload, Err := base64.RawURLEncoding.DecodeString(EncodedPayload)
+	if Err != nil {
+		return nil, false
+	}
+
+	Signature, Err := base64.RawURLEncoding.DecodeString(EncodedSignature)
+	if Err != nil || !hmac.Equal(Signature, ComputeTokenSignature(Payload)) {
+		return nil, false
+	}
+
+	var Decoded TTokenPayload
+	if Err := json.Unmarshal(Payload, &Decoded); Err != nil {
+		g_LogErr.Printf("Failed to decode token payload: %v", Err)
+		return nil, false
+	}
+
+	if Decoded.Purpose != Purpose || time.Now().Unix() >= Decoded.Expires {
+		return nil, false
+	}
+
+	return Decoded.Values, true
+}
+
+// Access Tokens
+// ==============================================================================
+// NOTE(fusion): Personal access tokens are random strings handed out once, when
+// created, and only their hash is stored by the query manager. There is no need
+// for a slow hash here since tokens have plenty of entropy, unlike passwords.
+const (
+	AccessTokenPrefix        = "tw_"
+	MaxAccessTokenNameLength = 32
+)
+
+func GenerateAccessToken() string {
+	var Random [32]byte
+	if _, Err := rand.Read(Random[:]); Err != nil {
+		g_LogErr.Printf("Failed to generate access token: %v", Err)
+		return ""
+	}
+	return AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(Random[:])
+}
+
+func HashAccessToken(Token string) string {
+	Hash := sha256.Sum256([]byte(Token))
+	return hex.EncodeToString(Hash[:])
+}
+
+func ParseAccessTokenScopes(String string) int {
+	switch String {
+	case "read":
+		return ACCESS_TOKEN_SCOPE_READ
+	case "characters":
+		return ACCESS_TOKEN_SCOPE_READ | ACCESS_TOKEN_SCOPE_CHARACTERS
+	default:
+		return 0
+	}
+}
+
+func AccessTokenScopesString(Scopes int) string {
+	if Scopes&ACCESS_TOKEN_SCOPE_CHARACTERS != 0 {
+		return "Manage Characters"
+	} else if Scopes&ACCESS_TOKEN_SCOPE_READ != 0 {
+		return "Read Only"
+	} else {
+		return "None"
+	}
+}